@@ -0,0 +1,419 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tmaxmax/go-sse/internal/hub"
+)
+
+// A BridgeMessage is a message forwarded between Joe instances through a Bridge.
+type BridgeMessage struct {
+	// The published message.
+	Message *Message
+	// The ID of the Joe instance the message was published to.
+	Origin string
+	// The topics the message was published to.
+	Topics []string
+}
+
+// A Bridge forwards the messages published to a Joe instance to other instances,
+// usually running in other processes, through an external bus. Set it as Joe's Bridge
+// to scale out: the subscribers of every instance receive the messages published
+// to any of the instances.
+//
+// Each message carries the ID of the instance it was published to, so that instances
+// ignore their own messages and messages are never forwarded in loops.
+//
+// Implementations must be thread-safe.
+type Bridge interface {
+	// Send forwards the message to the other instances. It is called after the message
+	// was published locally, from the goroutine which published it.
+	Send(m BridgeMessage) error
+	// Receive calls the given function for each message received from the bus, one at a time,
+	// until the context is done. Receive should handle transient errors of the bus itself:
+	// if it returns before the context is done, the instance doesn't receive messages anymore.
+	Receive(ctx context.Context, handle func(BridgeMessage)) error
+}
+
+// LoopbackBridge is an in-memory Bridge which forwards messages between the Joe instances
+// of the same process, for example in tests. The zero value is ready to use.
+type LoopbackBridge struct {
+	receivers map[*loopbackReceiver]struct{}
+	mu        sync.RWMutex
+}
+
+type loopbackReceiver struct {
+	messages chan BridgeMessage
+	done     chan struct{}
+}
+
+// loopbackBuffer is the number of messages buffered for each receiver of a LoopbackBridge.
+const loopbackBuffer = 64
+
+// Send sends the message to every receiver, including the one of the instance which sent it.
+// It blocks if a receiver didn't yet handle the previous messages.
+func (l *LoopbackBridge) Send(m BridgeMessage) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for r := range l.receivers {
+		select {
+		case r.messages <- m:
+		case <-r.done:
+		}
+	}
+
+	return nil
+}
+
+// Receive implements Bridge. It always returns nil.
+func (l *LoopbackBridge) Receive(ctx context.Context, handle func(BridgeMessage)) error {
+	r := &loopbackReceiver{messages: make(chan BridgeMessage, loopbackBuffer), done: make(chan struct{})}
+
+	l.mu.Lock()
+	if l.receivers == nil {
+		l.receivers = map[*loopbackReceiver]struct{}{}
+	}
+	l.receivers[r] = struct{}{}
+	l.mu.Unlock()
+
+	defer func() {
+		// Closing done first unblocks the senders, so the lock can be acquired.
+		close(r.done)
+
+		l.mu.Lock()
+		delete(l.receivers, r)
+		l.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case m := <-r.messages:
+			handle(m)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// DefaultBridgeWriteTimeout is the default time after which writing a message to a peer of a TCPBridge fails.
+const DefaultBridgeWriteTimeout = 5 * time.Second
+
+// Errors returned by TCPBridge.Send for the peers the message was dropped for.
+var (
+	// ErrBridgePeerDown is returned when the connection to the peer failed and isn't open again yet.
+	ErrBridgePeerDown = errors.New("go-sse.bridge: peer is down")
+	// ErrBridgeQueueFull is returned when the peer didn't yet receive the previous messages.
+	ErrBridgeQueueFull = errors.New("go-sse.bridge: peer queue is full")
+)
+
+const (
+	// bridgeQueueSize is the number of messages queued for each peer of a TCPBridge.
+	bridgeQueueSize = 256
+	// The bounds of the delay between the attempts to connect to a peer.
+	bridgeMinBackoff = 100 * time.Millisecond
+	bridgeMaxBackoff = 10 * time.Second
+)
+
+// TCPBridge is a reference Bridge implementation which connects Joe instances directly over TCP,
+// in a full mesh: each instance sends its messages to all the other instances and receives
+// their messages on its own listener. Messages are encoded as newline-delimited JSON.
+//
+// Each peer has its own connection, which is opened and written to in the background,
+// so a slow or unreachable peer never blocks publishing. Delivery is best-effort: messages
+// are dropped for a peer while its connection is down or while it doesn't keep up with
+// the published messages. After a failure, the connection is opened again with exponential
+// backoff. The bridge does no authentication or encryption, so use it only on trusted networks.
+type TCPBridge struct {
+	// Listener accepts the connections from the other instances. It is closed when
+	// Receive returns. If it is nil, the bridge only sends messages.
+	Listener net.Listener
+	// Dial opens the connections to the peers. If nil, net.Dialer.DialContext is used.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// The addresses of the other instances' listeners, as accepted by net.Dial.
+	Peers []string
+	// WriteTimeout bounds the time it takes to connect and write a message to a peer,
+	// after which the connection is considered down. Defaults to DefaultBridgeWriteTimeout.
+	WriteTimeout time.Duration
+
+	peers  map[string]*bridgePeer
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+}
+
+type bridgePeer struct {
+	address string
+	frames  chan *hub.Frame
+	down    atomic.Bool
+}
+
+// Send queues the message for all the peers, without waiting for it to be written.
+// It returns the errors of the peers the message was dropped for joined together.
+// After the bridge is closed, it returns net.ErrClosed.
+func (b *TCPBridge) Send(m BridgeMessage) error {
+	f := &hub.Frame{Kind: hub.KindBridge, Origin: m.Origin, Topics: m.Topics, Message: toHubMessage(m.Message)}
+
+	// Holding the lock while queueing keeps the messages in order for each peer.
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return net.ErrClosed
+	}
+
+	var errs []error
+	for _, address := range b.Peers {
+		if err := b.peer(address).queue(f); err != nil {
+			errs = append(errs, fmt.Errorf("go-sse.bridge: peer %s: %w", address, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close stops sending messages to the peers and closes the connections to them.
+// Messages which weren't yet written are lost. It doesn't stop Receive, so call it after
+// the Joe instance which uses the bridge is shut down.
+func (b *TCPBridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return net.ErrClosed
+	}
+	b.closed = true
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+
+	return nil
+}
+
+// peer returns the peer with the given address, starting its goroutine if necessary.
+// It must be called with the lock held.
+func (b *TCPBridge) peer(address string) *bridgePeer {
+	if p := b.peers[address]; p != nil {
+		return p
+	}
+
+	if b.peers == nil {
+		b.peers = map[string]*bridgePeer{}
+		b.ctx, b.cancel = context.WithCancel(context.Background())
+	}
+
+	p := &bridgePeer{address: address, frames: make(chan *hub.Frame, bridgeQueueSize)}
+	b.peers[address] = p
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.run(p)
+	}()
+
+	return p
+}
+
+// run connects to the peer and writes the queued messages to it until the bridge is closed.
+func (b *TCPBridge) run(p *bridgePeer) {
+	timeout := b.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultBridgeWriteTimeout
+	}
+
+	backoff := bridgeMinBackoff
+
+	for {
+		if err := b.serve(p, timeout); err == nil {
+			// The peer was reachable, so it is retried quickly.
+			backoff = bridgeMinBackoff
+		}
+
+		if b.ctx.Err() != nil {
+			return
+		}
+
+		p.fail()
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-b.ctx.Done():
+			t.Stop()
+			return
+		}
+
+		backoff = min(backoff*2, bridgeMaxBackoff)
+	}
+}
+
+// serve connects to the peer and writes the queued messages until writing fails.
+// It returns a nil error if the connection was opened.
+func (b *TCPBridge) serve(p *bridgePeer, timeout time.Duration) error {
+	dial := b.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	ctx, cancel := context.WithTimeout(b.ctx, timeout)
+	conn, err := dial(ctx, "tcp", p.address)
+	cancel()
+
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	p.down.Store(false)
+
+	frames := hub.NewConn(conn)
+	for {
+		select {
+		case f := <-p.frames:
+			_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+			if err := frames.Write(f); err != nil {
+				// The message may have been partially written, so the connection can't be used anymore.
+				return nil
+			}
+		case <-b.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// queue queues the message for the peer, unless it is down or its queue is full.
+func (p *bridgePeer) queue(f *hub.Frame) error {
+	if p.down.Load() {
+		return ErrBridgePeerDown
+	}
+
+	select {
+	case p.frames <- f:
+		return nil
+	default:
+		return ErrBridgeQueueFull
+	}
+}
+
+// fail marks the peer as down and drops the messages queued for it.
+func (p *bridgePeer) fail() {
+	p.down.Store(true)
+
+	for {
+		select {
+		case <-p.frames:
+		default:
+			return
+		}
+	}
+}
+
+// Receive accepts the connections of the other instances and handles the messages
+// they send until the context is done, when it closes the Listener and the connections.
+// It returns the error of the Listener, if it fails before the context is done.
+func (b *TCPBridge) Receive(ctx context.Context, handle func(BridgeMessage)) error {
+	if b.Listener == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	var (
+		conns    = map[net.Conn]struct{}{}
+		connsMu  sync.Mutex
+		handleMu sync.Mutex
+		wg       sync.WaitGroup
+		closing  bool
+	)
+
+	stop := func() {
+		connsMu.Lock()
+		defer connsMu.Unlock()
+
+		if closing {
+			return
+		}
+		closing = true
+
+		_ = b.Listener.Close()
+		for c := range conns {
+			_ = c.Close()
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-done:
+		}
+	}()
+
+	defer wg.Wait()
+	defer stop()
+
+	for {
+		c, err := b.Listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		connsMu.Lock()
+		if closing {
+			connsMu.Unlock()
+			_ = c.Close()
+			continue
+		}
+		conns[c] = struct{}{}
+		connsMu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				connsMu.Lock()
+				delete(conns, c)
+				connsMu.Unlock()
+
+				_ = c.Close()
+			}()
+
+			frames := hub.NewConn(c)
+			for {
+				var f hub.Frame
+				if err := frames.Read(&f); err != nil {
+					return
+				}
+
+				if f.Kind != hub.KindBridge || f.Message == nil {
+					continue
+				}
+
+				m, err := fromHubMessage(f.Message)
+				if err != nil {
+					continue
+				}
+
+				handleMu.Lock()
+				handle(BridgeMessage{Message: m, Origin: f.Origin, Topics: f.Topics})
+				handleMu.Unlock()
+			}
+		}()
+	}
+}
@@ -0,0 +1,284 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+// nextBridged returns the next message which isn't a probe sent by waitBridged.
+func (rs remoteSubscription) nextBridged(t *testing.T) string {
+	t.Helper()
+
+	for {
+		if s := rs.next(t); s != "data: probe\n\n" {
+			return s
+		}
+	}
+}
+
+// none checks that no message other than probes is received in the meantime.
+func (rs remoteSubscription) none(t *testing.T) {
+	t.Helper()
+
+	for {
+		select {
+		case m := <-rs.messages:
+			if s := m.String(); s != "data: probe\n\n" {
+				t.Fatalf("unexpected message %q", s)
+			}
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// waitBridged sends probes through the bridge until all the subscriptions receive one,
+// as the instances start receiving from the bridge asynchronously.
+func waitBridged(t *testing.T, b sse.Bridge, subs ...remoteSubscription) {
+	t.Helper()
+
+	probe := msg(t, "probe", "")
+	received := make([]bool, len(subs))
+
+	deadline := time.Now().Add(time.Second)
+	for pending := len(subs); pending > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("instances didn't receive from the bridge")
+		}
+
+		_ = b.Send(sse.BridgeMessage{Message: probe, Origin: "probe", Topics: []string{sse.DefaultTopic}})
+		time.Sleep(5 * time.Millisecond)
+
+		for i, rs := range subs {
+			for len(rs.messages) > 0 && !received[i] {
+				if (<-rs.messages).String() == "data: probe\n\n" {
+					received[i] = true
+					pending--
+				}
+			}
+		}
+	}
+}
+
+func TestJoe_Bridge(t *testing.T) {
+	t.Parallel()
+
+	bridge := &sse.LoopbackBridge{}
+
+	fin, err := sse.NewFiniteReplayer(10, false)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	a := &sse.Joe{Bridge: bridge, InstanceID: "a"}
+	b := &sse.Joe{Bridge: bridge, InstanceID: "b", Replayer: fin, DedupeWindow: time.Minute}
+	cleanupJoe(t, a)
+	cleanupJoe(t, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subA := subscribeRemote(t, ctx, a, sse.EventID{}, nil)
+	subB := subscribeRemote(t, ctx, b, sse.EventID{}, nil)
+	waitBridged(t, bridge, subA, subB)
+
+	tests.Equal(t, a.Publish(msg(t, "from a", "1"), []string{sse.DefaultTopic}), nil, "unexpected publish error")
+	tests.Equal(t, subA.nextBridged(t), "id: 1\ndata: from a\n\n", "local message should be received")
+	tests.Equal(t, subB.nextBridged(t), "id: 1\ndata: from a\n\n", "bridged message should be received")
+
+	_, err = b.PublishOnce("key", msg(t, "from b", "2"), []string{sse.DefaultTopic})
+	tests.Equal(t, err, nil, "unexpected publish error")
+	_, err = b.PublishOnce("key", msg(t, "duplicate", "3"), []string{sse.DefaultTopic})
+	tests.Equal(t, err, nil, "unexpected publish error")
+
+	tests.Equal(t, subA.nextBridged(t), "id: 2\ndata: from b\n\n", "bridged message should be received")
+	tests.Equal(t, subB.nextBridged(t), "id: 2\ndata: from b\n\n", "local message should be received")
+
+	// Instances ignore their own messages and duplicates are not forwarded.
+	subA.none(t)
+	subB.none(t)
+
+	replayed := subscribeRemote(t, ctx, b, sse.ID("1"), nil)
+	tests.Equal(t, replayed.next(t), "id: 2\ndata: from b\n\n", "bridged messages should be replayed")
+}
+
+func TestTCPBridge(t *testing.T) {
+	t.Parallel()
+
+	la, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.Equal(t, err, nil, "should listen")
+	lb, err := net.Listen("tcp", "127.0.0.1:0")
+	tests.Equal(t, err, nil, "should listen")
+
+	bridgeA := &sse.TCPBridge{Listener: la, Peers: []string{lb.Addr().String()}}
+	bridgeB := &sse.TCPBridge{Listener: lb, Peers: []string{la.Addr().String()}}
+	t.Cleanup(func() {
+		_ = bridgeA.Close()
+		_ = bridgeB.Close()
+	})
+
+	a := &sse.Joe{Bridge: bridgeA}
+	b := &sse.Joe{Bridge: bridgeB}
+	cleanupJoe(t, a)
+	cleanupJoe(t, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subA := subscribeRemote(t, ctx, a, sse.EventID{}, nil)
+	subB := subscribeRemote(t, ctx, b, sse.EventID{}, nil)
+
+	// The listeners already accept connections, so nothing sent from now on is lost.
+	m := msg(t, "from a", "1")
+	m.ConflationKey = "key"
+	tests.Equal(t, a.Publish(m, []string{sse.DefaultTopic}), nil, "unexpected publish error")
+	tests.Equal(t, b.Publish(msg(t, "from b", "2"), []string{sse.DefaultTopic}), nil, "unexpected publish error")
+
+	tests.Equal(t, subA.next(t), "id: 1\ndata: from a\n\n", "local message should be received")
+	tests.Equal(t, subA.next(t), "id: 2\ndata: from b\n\n", "bridged message should be received")
+	// The message from a may be received by b before b publishes its own.
+	receivedB := []string{subB.next(t), subB.next(t)}
+	slices.Sort(receivedB)
+	tests.DeepEqual(t, receivedB, []string{"id: 1\ndata: from a\n\n", "id: 2\ndata: from b\n\n"}, "local and bridged messages should be received")
+
+	unreachable := &sse.TCPBridge{Peers: []string{la.Addr().String()}, WriteTimeout: time.Second}
+	t.Cleanup(func() { _ = unreachable.Close() })
+	tests.Equal(t, a.Shutdown(context.Background()), nil, "unexpected shutdown error")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := unreachable.Send(sse.BridgeMessage{Message: msg(t, "lost", ""), Topics: []string{sse.DefaultTopic}})
+		if errors.Is(err, sse.ErrBridgePeerDown) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sending to a closed peer should fail")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	tests.Equal(t, unreachable.Close(), nil, "unexpected close error")
+	tests.Expect(t, errors.Is(unreachable.Send(sse.BridgeMessage{Message: msg(t, "lost", "")}), net.ErrClosed), "sending after close should fail")
+}
+
+func TestTCPBridge_unresponsivePeer(t *testing.T) {
+	t.Parallel()
+
+	dialed := make(chan struct{}, 1)
+	b := &sse.TCPBridge{
+		// The peer never answers, so connecting to it only fails after the timeout.
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			select {
+			case dialed <- struct{}{}:
+			default:
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		Peers:        []string{"blackhole"},
+		WriteTimeout: 50 * time.Millisecond,
+	}
+	t.Cleanup(func() { _ = b.Close() })
+
+	m := sse.BridgeMessage{Message: msg(t, "lost", ""), Topics: []string{sse.DefaultTopic}}
+
+	start := time.Now()
+	tests.Equal(t, b.Send(m), nil, "message should be queued while connecting")
+	<-dialed
+
+	var err error
+	for range 1000 {
+		if err = b.Send(m); err != nil {
+			break
+		}
+	}
+	tests.Expect(t, errors.Is(err, sse.ErrBridgeQueueFull), "messages should be dropped when the queue is full")
+	tests.Expect(t, time.Since(start) < 50*time.Millisecond, "sending should not wait for the peer")
+
+	deadline := time.Now().Add(time.Second)
+	for !errors.Is(b.Send(m), sse.ErrBridgePeerDown) {
+		if time.Now().After(deadline) {
+			t.Fatal("peer should be down after failing to connect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJoe_Bridge_encoded(t *testing.T) {
+	t.Parallel()
+
+	const count = 20
+
+	j := &sse.Joe{Bridge: &sse.LoopbackBridge{}}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	clients := []*encodedClient{
+		{encoded: make(chan *sse.EncodedMessage, count)},
+		{encoded: make(chan *sse.EncodedMessage, count)},
+	}
+
+	for _, c := range clients {
+		ctx, _ := newMockContext(t)
+		go func() { _ = j.Subscribe(ctx, sse.Subscription{Client: c, Topics: topics}) }()
+		<-ctx.waitingOnDone
+	}
+
+	// The messages are forwarded while they are encoded for the subscribers,
+	// which the race detector checks.
+	for range count {
+		tests.Equal(t, j.Publish(msg(t, "hello", ""), topics), nil, "unexpected publish error")
+	}
+
+	for _, c := range clients {
+		for range count {
+			tests.Equal(t, string((<-c.encoded).Bytes()), "data: hello\n\n", "invalid encoding")
+		}
+	}
+}
+
+// recordingBridge records the messages sent through it and never receives any.
+type recordingBridge struct {
+	sent []sse.BridgeMessage
+	mu   sync.Mutex
+}
+
+func (r *recordingBridge) Send(m sse.BridgeMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent = append(r.sent, m)
+	return nil
+}
+
+func (r *recordingBridge) Receive(ctx context.Context, _ func(sse.BridgeMessage)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestJoe_Bridge_replayerError(t *testing.T) {
+	t.Parallel()
+
+	// Messages with IDs can't be put into an autoID replayer.
+	fin, err := sse.NewFiniteReplayer(5, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	bridge := &recordingBridge{}
+	j := &sse.Joe{Bridge: bridge, Replayer: fin}
+	cleanupJoe(t, j)
+
+	err = j.Publish(msg(t, "hello", "7"), []string{sse.DefaultTopic})
+	tests.Expect(t, err != nil, "replayer error should be returned")
+
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+
+	tests.Equal(t, len(bridge.sent), 1, "message should be forwarded despite the replayer error")
+	tests.Equal(t, bridge.sent[0].Message.ID, sse.ID("7"), "invalid forwarded message")
+}
@@ -40,6 +40,15 @@ type Client struct {
 	// Backoff configures the backoff strategy. See the documentation of
 	// each field for more information.
 	Backoff Backoff
+	// AckURL enables delivery acknowledgements – see Server.AckHandler. If it is set,
+	// the ID of each event which has one is sent in a POST request to this URL after
+	// all the event's callbacks return. The request has the same headers as the
+	// connection's request, so that the server can identify the client.
+	//
+	// Acknowledgements are sent in the background and the IDs received in the meantime
+	// are batched together. They are best-effort: if an acknowledgement fails, the server
+	// sends the event again when the client reconnects.
+	AckURL string
 }
 
 // Backoff configures the reconnection strategy of a Connection.
@@ -0,0 +1,87 @@
+package sse
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acker sends the acknowledgements of a connection's events in the background.
+// All the IDs received while a request is in flight are sent together in the next request.
+type acker struct {
+	client *http.Client
+	header http.Header
+	ids    chan string
+	done   chan struct{}
+	url    string
+}
+
+func newAcker(c *Client, r *http.Request) *acker {
+	header := r.Header.Clone()
+	// These are the connection's own headers, the rest (for example, Authorization) identify the client.
+	for _, h := range [...]string{"Accept", "Cache", "Connection", "Last-Event-ID"} {
+		header.Del(h)
+	}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	return &acker{
+		client: c.HTTPClient,
+		header: header,
+		ids:    make(chan string, 64),
+		done:   make(chan struct{}),
+		url:    c.AckURL,
+	}
+}
+
+// add queues the ID for acknowledgement. It blocks if too many IDs are queued.
+func (a *acker) add(ctx context.Context, id string) {
+	select {
+	case a.ids <- id:
+	case <-ctx.Done():
+	}
+}
+
+func (a *acker) run(ctx context.Context) {
+	defer close(a.done)
+
+	for {
+		select {
+		case id := <-a.ids:
+			a.send(ctx, a.collect(id))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collect returns the given ID together with all the other queued IDs.
+func (a *acker) collect(id string) []string {
+	ids := []string{id}
+	for {
+		select {
+		case id := <-a.ids:
+			ids = append(ids, id)
+		default:
+			return ids
+		}
+	}
+}
+
+func (a *acker) send(ctx context.Context, ids []string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, strings.NewReader(strings.Join(ids, "\n")))
+	if err != nil {
+		return
+	}
+	req.Header = a.header.Clone()
+
+	// Acknowledgements are best-effort: the server sends the events
+	// whose acknowledgement failed again when the client reconnects.
+	res, err := a.client.Do(req)
+	if err != nil {
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+}
@@ -33,6 +33,7 @@ type Connection struct { //nolint:govet // The current order aids readability.
 	callbacksAll map[int]EventCallback
 	lastEventID  string
 	client       Client
+	acker        *acker
 	buf          []byte
 	bufMaxSize   int
 	callbackID   int
@@ -159,7 +160,7 @@ func (c *Connection) dispatch(ev Event) {
 	}
 }
 
-func (c *Connection) read(r io.Reader, setRetry func(time.Duration)) error {
+func (c *Connection) read(ctx context.Context, r io.Reader, setRetry func(time.Duration)) error {
 	pf := func() *parser.Parser {
 		p := parser.New(r)
 		if c.buf != nil || c.bufMaxSize > 0 {
@@ -168,14 +169,26 @@ func (c *Connection) read(r io.Reader, setRetry func(time.Duration)) error {
 		return p
 	}
 
-	var readErr error
-	read(pf, c.lastEventID, func(r int64) { setRetry(time.Duration(r) * time.Millisecond) }, false)(func(e Event, err error) bool {
+	var (
+		readErr error
+		// Whether the event being read has its own ID. Events redelivered after
+		// a reconnect may have the same ID as the previous event, so the IDs
+		// can't be compared to find out.
+		hasID bool
+	)
+	onRetry := func(r int64) { setRetry(time.Duration(r) * time.Millisecond) }
+	onID := func() { hasID = true }
+	read(pf, c.lastEventID, onRetry, onID, false)(func(e Event, err error) bool {
 		if err != nil {
 			readErr = err
 			return false
 		}
 		c.lastEventID = e.LastEventID
 		c.dispatch(e)
+		if c.acker != nil && hasID && e.LastEventID != "" {
+			c.acker.add(ctx, e.LastEventID)
+		}
+		hasID = false
 		return true
 	})
 
@@ -197,6 +210,17 @@ func (c *Connection) Connect() error {
 	ctx := c.request.Context()
 	backoff := c.client.Backoff.new()
 
+	if c.client.AckURL != "" {
+		c.acker = newAcker(&c.client, c.request)
+
+		ackCtx, cancel := context.WithCancel(ctx)
+		go c.acker.run(ackCtx)
+		defer func() {
+			cancel()
+			<-c.acker.done
+		}()
+	}
+
 	c.request.Header.Set("Accept", "text/event-stream")
 	c.request.Header.Set("Connection", "keep-alive")
 	c.request.Header.Set("Cache", "no-cache")
@@ -249,7 +273,7 @@ func (c *Connection) doConnect(ctx context.Context, setRetry func(time.Duration)
 
 	setRetry(0)
 
-	err = c.read(res.Body, setRetry)
+	err = c.read(ctx, res.Body, setRetry)
 	if errors.Is(err, ctx.Err()) {
 		return false, err
 	}
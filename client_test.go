@@ -629,3 +629,48 @@ func TestConnection_reconnect(t *testing.T) {
 	tests.Equal(t, err, ctx.Err(), "expected context error")
 	tests.DeepEqual(t, lastEventIDs, []string{"", "1", "2"}, "incorrect last event IDs")
 }
+
+func TestConnection_acksRedelivered(t *testing.T) {
+	acks := make(chan string, 10)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, _ *http.Request) {
+		// The event is sent again on each reconnect, as if it wasn't acknowledged.
+		fmt.Fprint(w, "id: 0\ndata: a\n\n")
+	})
+	mux.HandleFunc("/ack", func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		acks <- string(body)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c := &sse.Client{
+		HTTPClient:        ts.Client(),
+		AckURL:            ts.URL + "/ack",
+		Backoff:           sse.Backoff{InitialInterval: time.Millisecond},
+		ResponseValidator: sse.NoopValidator,
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.NewConnection(reqCtx(t, ctx, "", ts.URL+"/events", http.NoBody)).Connect() }()
+
+	// Acknowledgements sent while another is in flight are sent together.
+	var acked []string
+	for len(acked) < 2 {
+		select {
+		case ack := <-acks:
+			acked = append(acked, strings.Split(ack, "\n")...)
+		case <-time.After(time.Second):
+			t.Fatalf("redelivered event was not acknowledged, acknowledged %v", acked)
+		}
+	}
+	tests.DeepEqual(t, acked[:2], []string{"0", "0"}, "invalid acknowledged IDs")
+
+	cancel()
+	<-done
+}
@@ -26,33 +26,41 @@ func newSSE() *sse.Server {
 	rp, _ := sse.NewValidReplayer(time.Minute*5, true)
 	rp.GCInterval = time.Minute
 
+	shutdownMessage := &sse.Message{Type: sse.Type("close")}
+	// Adding data is necessary because spec-compliant clients
+	// do not dispatch events without data.
+	shutdownMessage.AppendData("bye")
+
 	return &sse.Server{
 		Provider: &sse.Joe{Replayer: rp},
+		// On shutdown a close message is sent to every client, so they can gracefully disconnect.
+		ShutdownMessage: shutdownMessage,
 		// If you are using a 3rd party library to generate a per-request logger, this
 		// can just be a simple wrapper over it.
 		Logger: func(r *http.Request) *slog.Logger {
 			return getLogger(r.Context())
 		},
-		OnSession: func(w http.ResponseWriter, r *http.Request) (topics []string, permitted bool) {
+		AcceptSession: func(r *http.Request) (topics []string, err error) {
 			topics = r.URL.Query()["topic"]
-			for _, topic := range topics {
-				if topic != topicRandomNumbers && topic != topicMetrics {
-					fmt.Fprintf(w, "invalid topic %q; supported are %q, %q", topic, topicRandomNumbers, topicMetrics)
-
-					// NOTE: if you are returning false to reject the subscription, we strongly recommend writing
-					// your own response code. Clients will receive a 200 code otherwise, which may be confusing.
-					w.WriteHeader(http.StatusBadRequest)
-					return nil, false
-				}
-			}
 			if len(topics) == 0 {
 				// Provide default topics, if none are given.
 				topics = []string{topicRandomNumbers, topicMetrics}
 			}
 
-			// the shutdown message is sent on the default topic
-			return append(topics, sse.DefaultTopic), true
+			return topics, nil
 		},
+		// The authorizer is consulted for every requested topic.
+		Authorizer: sse.AuthorizerFunc(func(_ *http.Request, _, topic string) ([]string, error) {
+			if topic != topicRandomNumbers && topic != topicMetrics {
+				// The server writes a problem details response with this status code and detail.
+				return nil, &sse.RejectionError{
+					Status: http.StatusBadRequest,
+					Detail: fmt.Sprintf("invalid topic %q; supported are %q, %q", topic, topicRandomNumbers, topicMetrics),
+				}
+			}
+
+			return []string{topic}, nil
+		}),
 	}
 }
 
@@ -89,16 +97,10 @@ func main() {
 		ErrorLog:          httpLogger,
 	}
 	s.RegisterOnShutdown(func() {
-		e := &sse.Message{Type: sse.Type("close")}
-		// Adding data is necessary because spec-compliant clients
-		// do not dispatch events without data.
-		e.AppendData("bye")
-		// Broadcast a close message so clients can gracefully disconnect.
-		_ = sseHandler.Publish(e)
-
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 		defer cancel()
 
+		// Shutdown sends the close message to all sessions and waits for them to end.
 		// We use a context with a timeout so the program doesn't wait indefinitely
 		// for connections to terminate. There may be misbehaving connections
 		// which may hang for an unknown timespan, so we just stop waiting on Shutdown
@@ -0,0 +1,90 @@
+// Command sse-hub runs a hub which shares a provider between multiple server processes.
+// Each process connects to it using an sse.RemoteProvider:
+//
+//	s := &sse.Server{
+//		Provider: &sse.RemoteProvider{Network: "unix", Address: "/tmp/sse-hub.sock"},
+//	}
+//
+// Messages published by any process are sent to the subscribers of all processes
+// and replayed from the hub's replayer.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+func main() {
+	network := flag.String("network", "unix", `the network to listen on: "unix" or "tcp"`)
+	address := flag.String("address", "/tmp/sse-hub.sock", "the address to listen on: a socket path or a host:port")
+	replay := flag.Int("replay", 1000, "the number of messages kept for replay; 0 disables replay")
+	replayTTL := flag.Duration("replay-ttl", 0, "keep messages for replay for this duration instead of keeping a fixed number of them")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	replayer, err := newReplayer(*replay, *replayTTL)
+	if err != nil {
+		logger.Error("invalid replay configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if *network == "unix" {
+		// A socket file left behind by a previous run would make listening fail.
+		if err := os.Remove(*address); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			logger.Error("failed to remove stale socket", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	l, err := net.Listen(*network, *address)
+	if err != nil {
+		logger.Error("failed to listen", "error", err)
+		os.Exit(1)
+	}
+
+	h := &sse.Hub{Provider: &sse.Joe{Replayer: replayer}, Logger: logger}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- h.Serve(l) }()
+
+	logger.Info("hub listening", "network", *network, "address", *address)
+
+	select {
+	case err := <-serveErr:
+		logger.Error("hub stopped", "error", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelShutdown()
+
+	// Subscriptions end and the servers' clients reconnect once the hub is back.
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("shutdown error", "error", err)
+	}
+}
+
+func newReplayer(count int, ttl time.Duration) (sse.Replayer, error) {
+	if ttl > 0 {
+		return sse.NewValidReplayer(ttl, true)
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+
+	return sse.NewFiniteReplayer(count, true)
+}
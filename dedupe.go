@@ -0,0 +1,46 @@
+package sse
+
+import "time"
+
+// dedupeWindow remembers the IDs of the messages published with an idempotency key
+// for a fixed period of time.
+type dedupeWindow struct {
+	ids map[string]EventID
+	// The keys in the order they were added. As the window is the same for all keys,
+	// they also expire in this order.
+	entries []dedupeEntry
+	window  time.Duration
+}
+
+type dedupeEntry struct {
+	added time.Time
+	key   string
+}
+
+// lookup returns the ID of the message published with the given key, if the key is not expired.
+func (d *dedupeWindow) lookup(key string, now time.Time) (EventID, bool) {
+	d.expire(now)
+
+	id, ok := d.ids[key]
+	return id, ok
+}
+
+// add remembers the ID of the message published with the given key.
+func (d *dedupeWindow) add(key string, id EventID, now time.Time) {
+	if d.ids == nil {
+		d.ids = map[string]EventID{}
+	}
+
+	d.ids[key] = id
+	d.entries = append(d.entries, dedupeEntry{added: now, key: key})
+}
+
+func (d *dedupeWindow) expire(now time.Time) {
+	i := 0
+	for ; i < len(d.entries) && now.Sub(d.entries[i].added) >= d.window; i++ {
+		delete(d.ids, d.entries[i].key)
+	}
+
+	// The underlying array is reclaimed the next time it has to grow.
+	d.entries = d.entries[i:]
+}
@@ -63,10 +63,12 @@ func Read(r io.Reader, cfg *ReadConfig) func(func(Event, error) bool) {
 	}
 
 	// We take a factory function for the parser so that Read can be inlined by the compiler.
-	return read(pf, "", nil, true)
+	return read(pf, "", nil, nil, true)
 }
 
-func read(pf func() *parser.Parser, lastEventID string, onRetry func(int64), ignoreEOF bool) func(func(Event, error) bool) {
+// read parses the events. onID, if set, is called for each "id" field, before
+// the event the field belongs to is yielded.
+func read(pf func() *parser.Parser, lastEventID string, onRetry func(int64), onID func(), ignoreEOF bool) func(func(Event, error) bool) {
 	return func(yield func(Event, error) bool) {
 		p := pf()
 
@@ -96,6 +98,9 @@ func read(pf func() *parser.Parser, lastEventID string, onRetry func(int64), ign
 
 				lastEventID = f.Value
 				dirty = true
+				if onID != nil {
+					onID()
+				}
 			case parser.FieldNameRetry:
 				n, err := strconv.ParseInt(f.Value, 10, 64)
 				if err != nil {
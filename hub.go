@@ -0,0 +1,335 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"github.com/tmaxmax/go-sse/internal/hub"
+)
+
+// ErrHubClosed is returned by Hub.Serve after the hub is shut down.
+var ErrHubClosed = errors.New("go-sse.hub: hub closed")
+
+// A Hub shares a Provider between multiple processes. Each process connects to
+// the hub using a RemoteProvider, so that messages published by any process are
+// sent to the subscribers of all processes, which also share the provider's replayer.
+//
+// The hub communicates over any stream connection – for example, Unix domain sockets
+// or TCP. It does no authentication of its own, so make sure only trusted processes
+// can connect to it. See the sse-hub command for a ready-to-use hub daemon.
+type Hub struct {
+	// The provider shared by the connected processes. If nil, a Joe without replay is used.
+	//
+	// The provider must call the subscriptions' Snapshot function, as Joe does: it is how
+	// the hub signals the end of replay, which is needed to send the snapshots of the
+	// RemoteProvider's subscriptions in order.
+	Provider Provider
+	// An optional logger for connection errors.
+	Logger *slog.Logger
+
+	listeners map[net.Listener]struct{}
+	conns     map[*hub.Conn]struct{}
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	initDone  sync.Once
+	closed    bool
+}
+
+func (h *Hub) init() {
+	h.initDone.Do(func() {
+		if h.Provider == nil {
+			h.Provider = &Joe{}
+		}
+
+		h.listeners = map[net.Listener]struct{}{}
+		h.conns = map[*hub.Conn]struct{}{}
+	})
+}
+
+// Serve accepts connections on the given listener and serves them. It blocks until
+// the listener fails or the hub is shut down, in which case ErrHubClosed is returned.
+// Serve can be called multiple times, with different listeners.
+func (h *Hub) Serve(l net.Listener) error {
+	h.init()
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrHubClosed
+	}
+	h.listeners[l] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.listeners, l)
+		h.mu.Unlock()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			h.mu.Lock()
+			closed := h.closed
+			h.mu.Unlock()
+
+			if closed {
+				return ErrHubClosed
+			}
+
+			return err
+		}
+
+		conn := hub.NewConn(c)
+
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			_ = conn.Close()
+			return ErrHubClosed
+		}
+		h.conns[conn] = struct{}{}
+		h.wg.Add(1)
+		h.mu.Unlock()
+
+		go h.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting connections and shuts down the provider, which ends all the
+// subscriptions of the connected processes. The connections are closed afterwards.
+// If the context is done before the connections are served, the context error is returned.
+//
+// Calling Shutdown multiple times does nothing but return ErrHubClosed.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.init()
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return ErrHubClosed
+	}
+	h.closed = true
+	for l := range h.listeners {
+		_ = l.Close()
+	}
+	h.mu.Unlock()
+
+	err := h.Provider.Shutdown(ctx)
+
+	h.mu.Lock()
+	for c := range h.conns {
+		_ = c.Close()
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if errors.Is(err, ErrProviderClosed) {
+		// The provider was shut down by someone else.
+		return nil
+	}
+
+	return err
+}
+
+func (h *Hub) serveConn(conn *hub.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		subs   = map[uint64]context.CancelFunc{}
+		subsMu sync.Mutex
+		// Waits for the subscriptions and the publishes.
+		wg sync.WaitGroup
+	)
+
+	defer func() {
+		cancel()
+		_ = conn.Close()
+		wg.Wait()
+
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+
+		h.wg.Done()
+	}()
+
+	for {
+		var f hub.Frame
+		if err := conn.Read(&f); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && h.Logger != nil {
+				h.Logger.Warn("sse: hub connection error", "error", err)
+			}
+
+			return
+		}
+
+		switch f.Kind {
+		case hub.KindSubscribe:
+			subsMu.Lock()
+			_, exists := subs[f.ID]
+			if !exists {
+				var subCtx context.Context
+				subCtx, subs[f.ID] = context.WithCancel(ctx)
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					h.subscribe(subCtx, conn, &f)
+
+					subsMu.Lock()
+					subs[f.ID]()
+					delete(subs, f.ID)
+					subsMu.Unlock()
+				}()
+			}
+			subsMu.Unlock()
+
+			if exists {
+				_ = conn.Write(&hub.Frame{Kind: hub.KindClosed, ID: f.ID, Error: "go-sse.hub: duplicate subscription ID"})
+			}
+		case hub.KindUnsubscribe:
+			subsMu.Lock()
+			if subCancel, ok := subs[f.ID]; ok {
+				// The subscription's goroutine removes it.
+				subCancel()
+			}
+			subsMu.Unlock()
+		case hub.KindPublish:
+			// Publishing may wait for the provider to send the message to the subscriptions
+			// of this connection, so it is done in the background to keep reading.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				res := &hub.Frame{Kind: hub.KindPublished, ID: f.ID}
+				setFrameError(res, h.publish(&f))
+
+				_ = conn.Write(res)
+			}()
+		default:
+			if h.Logger != nil {
+				h.Logger.Warn("sse: unknown hub frame", "kind", f.Kind)
+			}
+		}
+	}
+}
+
+func (h *Hub) subscribe(ctx context.Context, conn *hub.Conn, f *hub.Frame) {
+	sub := Subscription{
+		Client: &hubWriter{conn: conn, id: f.ID},
+		Topics: f.Topics,
+		Snapshot: func() []*Message {
+			// Replay is done, so the client can send its own snapshot.
+			_ = conn.Write(&hub.Frame{Kind: hub.KindSubscribed, ID: f.ID})
+			return nil
+		},
+	}
+
+	var err error
+	if f.LastEventID != nil {
+		sub.LastEventID, err = NewID(*f.LastEventID)
+	}
+	if err == nil && len(sub.Topics) == 0 {
+		err = ErrNoTopic
+	}
+	if err == nil {
+		err = h.Provider.Subscribe(ctx, sub)
+	}
+
+	if ctx.Err() != nil {
+		// The client ended the subscription or it disconnected.
+		return
+	}
+
+	res := &hub.Frame{Kind: hub.KindClosed, ID: f.ID}
+	setFrameError(res, err)
+
+	_ = conn.Write(res)
+}
+
+func (h *Hub) publish(f *hub.Frame) error {
+	if len(f.Topics) == 0 {
+		return ErrNoTopic
+	}
+	if f.Message == nil {
+		return errors.New("go-sse.hub: publish without message")
+	}
+
+	m, err := fromHubMessage(f.Message)
+	if err != nil {
+		return err
+	}
+
+	return h.Provider.Publish(m, f.Topics)
+}
+
+// hubWriter sends the messages of a subscription to the process which owns it.
+type hubWriter struct {
+	conn *hub.Conn
+	id   uint64
+}
+
+func (w *hubWriter) Send(m *Message) error {
+	return w.conn.Write(&hub.Frame{Kind: hub.KindMessage, ID: w.id, Message: toHubMessage(m)})
+}
+
+func (w *hubWriter) Flush() error {
+	return w.conn.Write(&hub.Frame{Kind: hub.KindFlush, ID: w.id})
+}
+
+func setFrameError(f *hub.Frame, err error) {
+	if err == nil {
+		return
+	}
+
+	f.Error = err.Error()
+
+	switch {
+	case errors.Is(err, ErrProviderClosed):
+		f.Code = hub.CodeProviderClosed
+	case errors.Is(err, ErrNoTopic):
+		f.Code = hub.CodeNoTopic
+	}
+}
+
+func toHubMessage(m *Message) *hub.Message {
+	hm := &hub.Message{Raw: m.String(), ConflationKey: m.ConflationKey}
+	if !m.Expiry.IsZero() {
+		hm.Expiry = &m.Expiry
+	}
+
+	return hm
+}
+
+func fromHubMessage(hm *hub.Message) (*Message, error) {
+	m := &Message{}
+	if hm.Raw != "" {
+		if err := m.UnmarshalText([]byte(hm.Raw)); err != nil {
+			return nil, err
+		}
+	}
+
+	m.ConflationKey = hm.ConflationKey
+	if hm.Expiry != nil {
+		m.Expiry = *hm.Expiry
+	}
+
+	return m, nil
+}
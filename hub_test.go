@@ -0,0 +1,214 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func startHub(t *testing.T) (*sse.Hub, string) {
+	t.Helper()
+
+	fin, err := sse.NewFiniteReplayer(10, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	address := filepath.Join(t.TempDir(), "hub.sock")
+
+	l, err := net.Listen("unix", address)
+	tests.Equal(t, err, nil, "should listen on the socket")
+
+	h := &sse.Hub{Provider: &sse.Joe{Replayer: fin}}
+
+	served := make(chan error, 1)
+	go func() { served <- h.Serve(l) }()
+
+	t.Cleanup(func() {
+		_ = h.Shutdown(context.Background())
+		tests.ErrorIs(t, <-served, sse.ErrHubClosed, "invalid Serve error")
+	})
+
+	return h, address
+}
+
+type remoteSubscription struct {
+	messages chan *sse.Message
+	err      chan error
+}
+
+// subscribeRemote subscribes to the provider and waits until the subscription is active.
+// The snapshot message, if any, is sent after replay and before the live messages.
+func subscribeRemote(t *testing.T, ctx context.Context, p sse.Provider, lastEventID sse.EventID, snapshot *sse.Message) remoteSubscription { //nolint
+	t.Helper()
+
+	rs := remoteSubscription{messages: make(chan *sse.Message, 10), err: make(chan error, 1)}
+	active := make(chan struct{})
+
+	go func() {
+		rs.err <- p.Subscribe(ctx, sse.Subscription{
+			Client: mockClient(func(m *sse.Message) error {
+				if m != nil {
+					rs.messages <- m
+				}
+				return nil
+			}),
+			LastEventID: lastEventID,
+			Topics:      []string{sse.DefaultTopic},
+			Snapshot: func() []*sse.Message {
+				defer close(active)
+				if snapshot == nil {
+					return nil
+				}
+				return []*sse.Message{snapshot}
+			},
+		})
+	}()
+
+	select {
+	case <-active:
+	case err := <-rs.err:
+		t.Fatalf("subscribe failed: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("subscription not active")
+	}
+
+	return rs
+}
+
+func (rs remoteSubscription) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case m := <-rs.messages:
+		return m.String()
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+		return ""
+	}
+}
+
+func TestHub(t *testing.T) {
+	t.Parallel()
+
+	h, address := startHub(t)
+
+	a := &sse.RemoteProvider{Address: address}
+	b := &sse.RemoteProvider{Network: "unix", Address: address}
+	t.Cleanup(func() {
+		_ = a.Shutdown(context.Background())
+		_ = b.Shutdown(context.Background())
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subB := subscribeRemote(t, ctx, b, sse.EventID{}, nil)
+
+	m := msg(t, "from a", "")
+	m.ConflationKey = "key"
+	tests.Equal(t, a.Publish(m, []string{sse.DefaultTopic}), nil, "unexpected publish error")
+	tests.Equal(t, subB.next(t), "id: 0\ndata: from a\n\n", "message published by another process should be received")
+
+	tests.Equal(t, b.Publish(msg(t, "from b", ""), []string{sse.DefaultTopic}), nil, "unexpected publish error")
+	tests.Equal(t, subB.next(t), "id: 1\ndata: from b\n\n", "message published by the same process should be received")
+
+	tests.ErrorIs(t, a.Publish(msg(t, "no topic", ""), nil), sse.ErrNoTopic, "publish without topics should fail")
+
+	snapshot := msg(t, "snapshot", "")
+	subA := subscribeRemote(t, ctx, a, sse.ID("0"), snapshot)
+	tests.Equal(t, subA.next(t), "id: 1\ndata: from b\n\n", "messages should be replayed from the hub")
+	tests.Equal(t, subA.next(t), "data: snapshot\n\n", "snapshot should be sent after replay")
+
+	tests.Equal(t, h.Shutdown(context.Background()), nil, "unexpected shutdown error")
+
+	for _, rs := range []remoteSubscription{subA, subB} {
+		select {
+		case err := <-rs.err:
+			tests.ErrorIs(t, err, sse.ErrHubDisconnected, "subscriptions should end when the hub is shut down")
+		case <-time.After(time.Second):
+			t.Fatal("subscription didn't end")
+		}
+	}
+
+	err := a.Publish(msg(t, "hub closed", ""), []string{sse.DefaultTopic})
+	tests.Expect(t, err != nil, "publish should fail after the hub is shut down")
+}
+
+func TestRemoteProvider_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	_, address := startHub(t)
+
+	p := &sse.RemoteProvider{Address: address}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := subscribeRemote(t, ctx, p, sse.EventID{}, nil)
+
+	tests.Equal(t, p.Shutdown(context.Background()), nil, "unexpected shutdown error")
+	tests.ErrorIs(t, <-sub.err, sse.ErrProviderClosed, "subscription should end with the provider closed error")
+	tests.ErrorIs(t, p.Shutdown(context.Background()), sse.ErrProviderClosed, "second shutdown should fail")
+	tests.ErrorIs(t, p.Publish(msg(t, "closed", ""), []string{sse.DefaultTopic}), sse.ErrProviderClosed, "publish should fail after shutdown")
+
+	var opErr *net.OpError
+	err := (&sse.RemoteProvider{Address: filepath.Join(t.TempDir(), "missing.sock")}).Publish(msg(t, "x", ""), []string{sse.DefaultTopic})
+	tests.Expect(t, errors.As(err, &opErr), "publish should fail if the hub is unreachable")
+}
+
+func TestRemoteProvider_slowSubscription(t *testing.T) {
+	t.Parallel()
+
+	_, address := startHub(t)
+
+	p := &sse.RemoteProvider{Address: address}
+	t.Cleanup(func() { _ = p.Shutdown(context.Background()) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	release := make(chan struct{})
+
+	active := make(chan struct{})
+	slowErr := make(chan error, 1)
+	go func() {
+		slowErr <- p.Subscribe(ctx, sse.Subscription{
+			Client: mockClient(func(m *sse.Message) error {
+				if m != nil {
+					<-release
+				}
+				return nil
+			}),
+			Topics: []string{sse.DefaultTopic},
+			Snapshot: func() []*sse.Message {
+				close(active)
+				return nil
+			},
+		})
+	}()
+	<-active
+
+	fast := subscribeRemote(t, ctx, p, sse.EventID{}, nil)
+
+	// The slow client blocks on the first message, so its queue fills up.
+	for i := range 1000 {
+		tests.Equal(t, p.Publish(msg(t, "hello", ""), []string{sse.DefaultTopic}), nil, "unexpected publish error")
+		tests.Equal(t, fast.next(t), fmt.Sprintf("id: %d\ndata: hello\n\n", i), "other subscriptions should receive the messages")
+	}
+
+	// The subscription ends once its client returns.
+	close(release)
+
+	select {
+	case err := <-slowErr:
+		tests.ErrorIs(t, err, sse.ErrSlowSubscription, "slow subscription should end")
+	case <-time.After(time.Second):
+		t.Fatal("slow subscription didn't end")
+	}
+}
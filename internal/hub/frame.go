@@ -0,0 +1,139 @@
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kind is the type of a frame.
+type Kind string
+
+// Frames sent by clients to the hub.
+const (
+	// KindSubscribe subscribes to the frame's topics, replaying from the frame's LastEventID.
+	// The frame's ID identifies the subscription in all the frames that refer to it.
+	KindSubscribe Kind = "subscribe"
+	// KindUnsubscribe ends the subscription with the frame's ID.
+	KindUnsubscribe Kind = "unsubscribe"
+	// KindPublish publishes the frame's message to the frame's topics. The hub
+	// answers with a KindPublished frame with the same ID.
+	KindPublish Kind = "publish"
+)
+
+// Frames sent by the hub to clients.
+const (
+	// KindSubscribed is sent after the subscription's replayed messages
+	// and before any of the live messages.
+	KindSubscribed Kind = "subscribed"
+	// KindMessage sends the frame's message to the subscription.
+	KindMessage Kind = "message"
+	// KindFlush flushes the messages sent to the subscription.
+	KindFlush Kind = "flush"
+	// KindClosed is sent when the hub ends the subscription, with the reason in the frame's error.
+	// It isn't sent for subscriptions ended by clients.
+	KindClosed Kind = "closed"
+	// KindPublished is the result of a publish. The frame's error is empty on success.
+	KindPublished Kind = "published"
+)
+
+// Frames sent between bridged Joe instances.
+const (
+	// KindBridge forwards the frame's message, published to the frame's topics
+	// by the instance with the frame's origin ID. It isn't answered.
+	KindBridge Kind = "bridge"
+)
+
+// Error codes of well-known errors, so clients can map them to their own errors.
+const (
+	CodeProviderClosed = "provider_closed"
+	CodeNoTopic        = "no_topic"
+)
+
+// A Frame is the unit of communication between the hub and its clients, and between bridged instances.
+// Each frame is encoded as a single line of JSON.
+type Frame struct {
+	Message     *Message `json:"message,omitempty"`
+	LastEventID *string  `json:"lastEventID,omitempty"`
+	Kind        Kind     `json:"kind"`
+	Origin      string   `json:"origin,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Code        string   `json:"code,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+	ID          uint64   `json:"id"`
+}
+
+// A Message is a message in its standard textual representation together
+// with the fields which aren't part of that representation.
+type Message struct {
+	Expiry        *time.Time `json:"expiry,omitempty"`
+	Raw           string     `json:"raw"`
+	ConflationKey string     `json:"conflationKey,omitempty"`
+}
+
+// MaxFrameSize is the maximum size of a frame, in bytes.
+const MaxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is returned when writing a frame larger than MaxFrameSize.
+var ErrFrameTooLarge = errors.New("hub: frame too large")
+
+// Conn reads and writes frames. Reads must not be done concurrently, but writes can be.
+type Conn struct {
+	rwc io.ReadWriteCloser
+	sc  *bufio.Scanner
+	mu  sync.Mutex
+}
+
+// NewConn creates a Conn which communicates over the given connection.
+func NewConn(rwc io.ReadWriteCloser) *Conn {
+	sc := bufio.NewScanner(rwc)
+	sc.Buffer(nil, MaxFrameSize)
+
+	return &Conn{rwc: rwc, sc: sc}
+}
+
+// Read reads the next frame. It returns io.EOF if the connection was closed by the other side.
+func (c *Conn) Read(f *Frame) error {
+	if !c.sc.Scan() {
+		if err := c.sc.Err(); err != nil {
+			return err
+		}
+
+		return io.EOF
+	}
+
+	*f = Frame{}
+	if err := json.Unmarshal(c.sc.Bytes(), f); err != nil {
+		return fmt.Errorf("hub: invalid frame: %w", err)
+	}
+
+	return nil
+}
+
+// Write writes the frame with a single write call.
+func (c *Conn) Write(f *Frame) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if len(data) >= MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	data = append(data, '\n')
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.rwc.Write(data)
+	return err
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.rwc.Close()
+}
@@ -0,0 +1,64 @@
+package hub_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse/internal/hub"
+)
+
+func TestConn(t *testing.T) {
+	t.Parallel()
+
+	a, b := net.Pipe()
+	ca, cb := hub.NewConn(a), hub.NewConn(b)
+
+	lastEventID := "5"
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	frames := []hub.Frame{
+		{Kind: hub.KindSubscribe, ID: 1, Topics: []string{"a", "b"}, LastEventID: &lastEventID},
+		{Kind: hub.KindMessage, ID: 1, Message: &hub.Message{Raw: "id: 6\ndata: x\n\n", ConflationKey: "k", Expiry: &expiry}},
+		{Kind: hub.KindPublished, ID: 2, Error: "closed", Code: hub.CodeProviderClosed},
+	}
+
+	go func() {
+		for i := range frames {
+			if err := ca.Write(&frames[i]); err != nil {
+				t.Errorf("unexpected write error: %v", err)
+			}
+		}
+		_ = ca.Close()
+	}()
+
+	for i := range frames {
+		var f hub.Frame
+		if err := cb.Read(&f); err != nil {
+			t.Fatalf("unexpected read error: %v", err)
+		}
+		if !reflect.DeepEqual(f, frames[i]) {
+			t.Fatalf("invalid frame:\nreceived: %#v\nexpected: %#v", f, frames[i])
+		}
+	}
+
+	var f hub.Frame
+	if err := cb.Read(&f); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestConn_Write_tooLarge(t *testing.T) {
+	t.Parallel()
+
+	a, _ := net.Pipe()
+	c := hub.NewConn(a)
+
+	err := c.Write(&hub.Frame{Kind: hub.KindMessage, Message: &hub.Message{Raw: strings.Repeat("x", hub.MaxFrameSize)}})
+	if !errors.Is(err, hub.ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge, got %v", err)
+	}
+}
@@ -2,8 +2,13 @@ package sse
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"maps"
 	"runtime/debug"
+	"slices"
 	"sync"
+	"time"
 )
 
 // A Replayer is a type that can replay older published events to new subscribers.
@@ -62,6 +67,8 @@ type Replayer interface {
 	// or if all messages were replayed successfully.
 	//
 	// If any messages are replayed, Client.Flush must be called by implementations.
+	// Joe doesn't send the replayed messages rejected by the subscription's Filter and
+	// the expired messages – see Message.Expiry –, so implementations aren't required to skip them.
 	Replay(subscription Subscription) error
 }
 
@@ -74,12 +81,22 @@ type (
 
 	messageWithTopics struct {
 		message *Message
+		// The message encoded once, when it is first sent to an EncodedMessageWriter.
+		encoded *EncodedMessage
 		topics  []string
 	}
 
 	publishedMessage struct {
 		replayerErr chan<- error
-		messageWithTopics
+		// The ID the message was published with, set only for messages published with a key.
+		id *EventID
+		// Set to true if the message was dropped as a duplicate, set only for messages published with a key.
+		duplicate *bool
+		// The messages to forward through the bridge, set before anything is sent on replayerErr.
+		// It is nil if the messages aren't forwarded.
+		bridged  *[]BridgeMessage
+		key      string
+		messages []messageWithTopics
 	}
 )
 
@@ -87,7 +104,8 @@ type (
 // Events are also sent synchronously to subscribers, so if a subscriber's callback blocks, the others
 // have to wait.
 //
-// Joe optionally supports event replaying with the help of a Replayer.
+// Joe optionally supports event replaying with the help of a Replayer. Subscription snapshots
+// are sent after the replayed messages, before the subscriber receives any live message.
 //
 // If the replayer panics, the subscription for which it panicked is considered failed
 // and an error is returned, and thereafter the replayer is not used anymore – no replays
@@ -101,14 +119,62 @@ type Joe struct {
 	message        chan publishedMessage
 	subscription   chan subscription
 	unsubscription chan subscriber
+	calls          chan func(replay *Replayer)
 	done           chan struct{}
 	closed         chan struct{}
 	subscribers    map[subscriber]Subscription
+	conflated      map[subscriber]*conflated
+	dedupe         dedupeWindow
+	topics         map[string]int
+	observer       Observer
+	published      uint64
 
 	// An optional replayer that Joe uses to resend older messages to new subscribers.
 	Replayer Replayer
-
-	initDone sync.Once
+	// An optional observer which is notified of published messages and send errors.
+	Observer Observer
+	// OnTopicActive is called when a topic gets its first subscriber.
+	// Use it together with OnTopicInactive to run expensive producers
+	// only while someone is subscribed to their topic.
+	//
+	// Both callbacks are called in order, from Joe's event loop: they must return
+	// quickly and they must not call Joe's methods synchronously, as Joe can't do
+	// anything else while they run. Start or stop your producers in separate goroutines.
+	OnTopicActive func(topic string)
+	// OnTopicInactive is called when the last subscriber of a topic leaves.
+	// When Joe is shut down, it is called for every topic which still has subscribers.
+	OnTopicInactive func(topic string)
+	// ConflationInterval enables conflation of messages which have a ConflationKey:
+	// instead of being sent immediately, they are held for each subscriber and only
+	// the newest message of each key is sent, at most once per interval. This limits
+	// the rate of high-frequency updates and relieves slow subscribers.
+	//
+	// Messages without a key are still sent immediately, so they may be sent before
+	// older conflated messages. Replayed messages are never conflated.
+	// Conflation is disabled if the interval is zero.
+	ConflationInterval time.Duration
+	// DedupeWindow is how long Joe remembers the idempotency keys given to PublishOnce.
+	// Messages published with a key seen within the window are dropped. If it is zero,
+	// messages are never deduplicated.
+	DedupeWindow time.Duration
+	// An optional bridge which forwards the published messages to other Joe instances
+	// and publishes the messages received from them. Messages are forwarded after they
+	// are put into the replayer, so they keep the ID the replayer gave them. Instances
+	// which receive them put them into their own replayer, which must accept messages
+	// with IDs – autoID replayers reject them, so the messages are sent, but not replayed.
+	//
+	// Duplicates dropped by PublishOnce are not forwarded and messages received from
+	// the bridge are never forwarded again. The publish methods return the errors
+	// of the bridge, but the messages are published locally regardless.
+	Bridge Bridge
+	// InstanceID identifies this instance in the messages forwarded through the Bridge.
+	// It must be unique among the bridged instances. If empty, a random ID is used.
+	InstanceID string
+	// An optional logger for the errors of the Bridge's Receive method.
+	Logger *slog.Logger
+
+	instanceID string
+	initDone   sync.Once
 }
 
 // Subscribe tells Joe to send new messages to this subscriber. The subscription
@@ -171,15 +237,183 @@ func (j *Joe) Publish(msg *Message, topics []string) error {
 	// message published causes an error after the shutdown.
 	errs := make(chan error, 1)
 
-	pub := publishedMessage{replayerErr: errs}
-	pub.message = msg
-	pub.topics = topics
+	pub := publishedMessage{
+		replayerErr: errs,
+		messages:    []messageWithTopics{{message: msg, topics: topics}},
+	}
+
+	return j.publish(pub, errs)
+}
+
+// PublishOnce tells Joe to send the given message to the subscribers, unless a message
+// with the same idempotency key was published within the DedupeWindow. Duplicates are
+// neither sent nor put into the replayer.
+//
+// It returns the ID the message was published with – the one set by the replayer,
+// if it sets IDs – or, for duplicates, the ID of the original message. The errors
+// are the same as Publish's. Messages for which the replayer returns an error are
+// still remembered, as they are sent to subscribers. Messages with an empty key are
+// never deduplicated.
+func (j *Joe) PublishOnce(key string, msg *Message, topics []string) (EventID, error) {
+	if len(topics) == 0 {
+		return EventID{}, ErrNoTopic
+	}
+
+	j.init()
+
+	errs := make(chan error, 1)
+
+	var id EventID
+	pub := publishedMessage{
+		replayerErr: errs,
+		id:          &id,
+		duplicate:   new(bool),
+		key:         key,
+		messages:    []messageWithTopics{{message: msg, topics: topics}},
+	}
+
+	err := j.publish(pub, errs)
+	return id, err
+}
+
+// PublishBatch tells Joe to send all the given messages to the subscribers.
+// The messages are put into the replayer in order without any other operation
+// being executed in between, and each subscriber receives all the messages
+// it is subscribed to before being flushed a single time.
+//
+// It returns ErrNoTopic if any of the publications has no topics, in which case
+// nothing is published, ErrProviderClosed or the Replayer.Put errors joined together.
+// Just like for Publish, messages for which the replayer returns an error
+// are still sent.
+func (j *Joe) PublishBatch(batch []Publication) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	messages := make([]messageWithTopics, 0, len(batch))
+	for _, p := range batch {
+		if len(p.Topics) == 0 {
+			return ErrNoTopic
+		}
+
+		messages = append(messages, messageWithTopics{message: p.Message, topics: p.Topics})
+	}
+
+	j.init()
+
+	errs := make(chan error, 1)
+
+	return j.publish(publishedMessage{replayerErr: errs, messages: messages}, errs)
+}
+
+func (j *Joe) publish(pub publishedMessage, errs <-chan error) error {
+	var bridged []BridgeMessage
+	if j.Bridge != nil {
+		pub.bridged = &bridged
+	}
 
 	// Waiting on done ensures Publish doesn't block the caller goroutine
 	// when Joe is stopped and implements the required Provider behavior.
 	select {
 	case j.message <- pub:
-		return <-errs
+		err := <-errs
+		// The messages to forward were set before the error, if any, was sent. They don't
+		// share any state with the messages being dispatched, so they can be read here.
+		if ferr := j.forward(bridged); ferr != nil {
+			return errors.Join(err, ferr)
+		}
+
+		return err
+	case <-j.done:
+		return ErrProviderClosed
+	}
+}
+
+// forward sends the published messages to the other instances through the bridge.
+func (j *Joe) forward(msgs []BridgeMessage) error {
+	var errs []error
+	for _, m := range msgs {
+		if err := j.Bridge.Send(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// receive publishes a message received from the bridge, unless it was published by this instance.
+func (j *Joe) receive(m BridgeMessage) {
+	if m.Origin == j.instanceID || m.Message == nil || len(m.Topics) == 0 {
+		return
+	}
+
+	// Replayer errors can't be reported to anyone, so they are ignored.
+	errs := make(chan error, 1)
+	pub := publishedMessage{replayerErr: errs, messages: []messageWithTopics{{message: m.Message, topics: m.Topics}}}
+
+	select {
+	case j.message <- pub:
+		<-errs
+	case <-j.done:
+	}
+}
+
+// Stats returns a snapshot of Joe's state. The snapshot is consistent, as it
+// is created by Joe between two operations. If the replayer implements
+// StatsReplayer, its statistics are included too.
+//
+// It returns ErrProviderClosed if Joe is closed.
+func (j *Joe) Stats() (ProviderStats, error) {
+	var stats ProviderStats
+	if err := j.call(func(replay *Replayer) { stats = j.snapshot(replay) }); err != nil {
+		return ProviderStats{}, err
+	}
+
+	return stats, nil
+}
+
+// History returns the stored messages of the given topic which can still be replayed,
+// if the replayer implements HistoryReplayer. Otherwise, it returns errors.ErrUnsupported.
+//
+// It returns ErrProviderClosed if Joe is closed.
+func (j *Joe) History(topic string) ([]*Message, error) {
+	var msgs []*Message
+	var err error
+	if cerr := j.call(func(replay *Replayer) { msgs, err = tryHistory(topic, replay) }); cerr != nil {
+		return nil, cerr
+	}
+
+	return msgs, err
+}
+
+// Purge removes the history of the given topic from the replayer, if the replayer
+// implements HistoryReplayer. Otherwise, it returns errors.ErrUnsupported.
+//
+// It returns ErrProviderClosed if Joe is closed.
+func (j *Joe) Purge(topic string) (int, error) {
+	var n int
+	var err error
+	if cerr := j.call(func(replay *Replayer) { n, err = tryPurge(topic, replay) }); cerr != nil {
+		return 0, cerr
+	}
+
+	return n, err
+}
+
+// call runs the given function inside the event loop, between two operations.
+func (j *Joe) call(fn func(replay *Replayer)) error {
+	j.init()
+
+	done := make(chan struct{})
+	call := func(replay *Replayer) {
+		defer close(done)
+		fn(replay)
+	}
+
+	select {
+	case j.calls <- call:
+		<-done
+		return nil
 	case <-j.done:
 		return ErrProviderClosed
 	}
@@ -209,56 +443,96 @@ func (j *Joe) Shutdown(ctx context.Context) (err error) {
 	return
 }
 
+func (j *Joe) addSubscriber(done subscriber, sub Subscription) { //nolint:gocritic // intended
+	j.subscribers[done] = sub
+
+	for i, t := range sub.Topics {
+		if slices.Contains(sub.Topics[:i], t) {
+			continue
+		}
+
+		j.topics[t]++
+		if j.topics[t] == 1 && j.OnTopicActive != nil {
+			j.OnTopicActive(t)
+		}
+	}
+}
+
 func (j *Joe) removeSubscriber(sub subscriber) {
-	l := len(j.subscribers)
-	delete(j.subscribers, sub)
-	// We check that an element was deleted as removeSubscriber is called twice
+	s, ok := j.subscribers[sub]
+	// We check that an element exists as removeSubscriber is called twice
 	// in the following edge case: the subscriber context is done before a
 	// published message is sent/flushed, and the send/flush returns an error.
-	if l != len(j.subscribers) {
-		close(sub)
+	if !ok {
+		return
+	}
+
+	delete(j.subscribers, sub)
+	delete(j.conflated, sub)
+	close(sub)
+
+	for i, t := range s.Topics {
+		if slices.Contains(s.Topics[:i], t) {
+			continue
+		}
+
+		decrement(j.topics, t)
+		if j.topics[t] == 0 && j.OnTopicInactive != nil {
+			j.OnTopicInactive(t)
+		}
+	}
+}
+
+// deactivateTopics calls OnTopicInactive for all topics which still have subscribers.
+func (j *Joe) deactivateTopics() {
+	if j.OnTopicInactive == nil {
+		return
+	}
+
+	topics := make([]string, 0, len(j.topics))
+	for t := range j.topics {
+		topics = append(topics, t)
+	}
+	slices.Sort(topics)
+
+	for _, t := range topics {
+		j.OnTopicInactive(t)
 	}
 }
 
 func (j *Joe) start(replay Replayer) {
 	defer close(j.closed)
 
+	var conflationTick <-chan time.Time
+	if j.ConflationInterval > 0 {
+		t := time.NewTicker(j.ConflationInterval)
+		defer t.Stop()
+
+		conflationTick = t.C
+	}
+
 	for {
 		select {
 		case msg := <-j.message:
+			if j.isDuplicate(msg) {
+				close(msg.replayerErr)
+				continue
+			}
+
+			var err error
 			if replay != nil {
-				m, err := tryPut(msg.messageWithTopics, &replay)
-				if _, isPanic := err.(replayPanic); err != nil && !isPanic { //nolint:errorlint // it's our error
-					// NOTE(tmaxmax): We could return panic errors here but we'd have to expose
-					// the error type in order for this error to be handled. Let's not change
-					// the public errors for now. See also the other note below.
-					msg.replayerErr <- err
-				} else if m != nil {
-					msg.message = m
-				}
+				err = putAll(msg.messages, &replay)
+			}
+			// The publisher reads the ID and the messages to forward as soon
+			// as it receives the error, so they must be set before it is sent.
+			j.remember(msg)
+			j.bridge(msg)
+			if err != nil {
+				msg.replayerErr <- err
 			}
 			close(msg.replayerErr)
 
-			for done, sub := range j.subscribers {
-				if topicsIntersect(sub.Topics, msg.topics) {
-					err := sub.Client.Send(msg.message)
-					if err == nil {
-						err = sub.Client.Flush()
-					}
-
-					if err != nil {
-						done <- err
-						// Technically it would be possible to just send the error,
-						// as Subscribe would send an unsubscription signal. The problem
-						// is that if the j.message channel is ready together with j.unsubscription
-						// and j.message is picked we might send again to this now unsubscribed
-						// subscriber, which will cause issues (e.g. deadlock on done).
-						// This line here is the reason why we need to verify we actually
-						// have this subscriber in removeSubscriber above.
-						j.removeSubscriber(done)
-					}
-				}
-			}
+			j.dispatch(msg.messages)
 		case sub := <-j.subscription:
 			var err error
 			if replay != nil {
@@ -269,23 +543,299 @@ func (j *Joe) start(replay Replayer) {
 			// other than disabling replay altogether. This ensures uptime
 			// in the face of unexpected – returning the panic as an error
 			// to the subscriber doesn't make sense, as it's probably not the subscriber's fault.
-			if _, isPanic := err.(replayPanic); err != nil && !isPanic { //nolint:errorlint // it's our error
+			if _, isPanic := err.(replayPanic); isPanic { //nolint:errorlint // it's our error
+				err = nil
+			}
+			if err == nil {
+				err = sendSnapshot(sub.Subscription)
+			}
+
+			if err != nil {
+				j.observer.SendError(err)
 				sub.done <- err
 				close(sub.done)
 			} else {
-				j.subscribers[sub.done] = sub.Subscription
+				j.addSubscriber(sub.done, sub.Subscription)
 			}
 		case sub := <-j.unsubscription:
 			j.removeSubscriber(sub)
+		case call := <-j.calls:
+			call(&replay)
+		case <-conflationTick:
+			j.flushConflated()
 		case <-j.done:
+			j.deactivateTopics()
 			return
 		}
 	}
 }
 
+// isDuplicate reports whether a message with the same idempotency key was already published,
+// in which case the original message's ID is returned.
+func (j *Joe) isDuplicate(msg publishedMessage) bool { //nolint:gocritic // intended
+	if msg.key == "" || j.DedupeWindow <= 0 {
+		return false
+	}
+
+	id, ok := j.dedupe.lookup(msg.key, time.Now())
+	if ok {
+		*msg.id = id
+		*msg.duplicate = true
+	}
+
+	return ok
+}
+
+// bridge sets the messages to forward through the bridge. They are built here, as the
+// messages are updated by the replayer and then used by the subscribers.
+func (j *Joe) bridge(msg publishedMessage) { //nolint:gocritic // intended
+	if msg.bridged == nil {
+		return
+	}
+
+	bridged := make([]BridgeMessage, 0, len(msg.messages))
+	for _, m := range msg.messages {
+		bridged = append(bridged, BridgeMessage{Message: m.message, Origin: j.instanceID, Topics: m.topics})
+	}
+
+	*msg.bridged = bridged
+}
+
+// remember returns the ID of a message published with an idempotency key
+// and remembers it, so that duplicates are dropped.
+func (j *Joe) remember(msg publishedMessage) { //nolint:gocritic // intended
+	if msg.id == nil {
+		return
+	}
+
+	*msg.id = msg.messages[0].message.ID
+	if msg.key != "" && j.DedupeWindow > 0 {
+		j.dedupe.add(msg.key, *msg.id, time.Now())
+	}
+}
+
+// sendSnapshot sends the subscription's snapshot, if any, to its client.
+func sendSnapshot(sub Subscription) error { //nolint:gocritic // intended
+	if sub.Snapshot == nil {
+		return nil
+	}
+
+	sent := false
+	for _, m := range sub.Snapshot() {
+		if sub.Filter != nil && !sub.Filter(m) {
+			continue
+		}
+		if err := sub.Client.Send(m); err != nil {
+			return err
+		}
+		sent = true
+	}
+
+	if sent {
+		return sub.Client.Flush()
+	}
+
+	return nil
+}
+
+func (j *Joe) dispatch(msgs []messageWithTopics) {
+	for i := range msgs {
+		j.observer.MessagePublished(msgs[i].message, msgs[i].topics)
+	}
+	j.published += uint64(len(msgs))
+
+	for done, sub := range j.subscribers {
+		var err error
+		sent := false
+
+		for i := range msgs {
+			// Sending to previous subscribers might have taken long enough for the message to expire.
+			if !sub.accepts(msgs[i].message, msgs[i].topics) || expiredNow(msgs[i].message) {
+				continue
+			}
+
+			if j.ConflationInterval > 0 && msgs[i].message.ConflationKey != "" {
+				j.conflate(done, &msgs[i])
+				continue
+			}
+
+			if err = msgs[i].sendTo(sub.Client); err != nil {
+				break
+			}
+			sent = true
+		}
+
+		if err == nil && sent {
+			err = sub.Client.Flush()
+		}
+
+		if err != nil {
+			j.fail(done, err)
+		}
+	}
+}
+
+// expiredNow reports whether the message is expired, without reading the clock for messages without expiry.
+func expiredNow(m *Message) bool {
+	return !m.Expiry.IsZero() && m.Expired(time.Now())
+}
+
+// conflated holds the newest message of each conflation key which wasn't yet sent to a subscriber.
+type conflated struct {
+	pending map[string]*messageWithTopics
+	// The keys in the order their first pending message was published.
+	keys []string
+}
+
+// conflate replaces the subscriber's pending message which has the same conflation key.
+func (j *Joe) conflate(done subscriber, m *messageWithTopics) {
+	c := j.conflated[done]
+	if c == nil {
+		c = &conflated{pending: map[string]*messageWithTopics{}}
+		j.conflated[done] = c
+	}
+
+	key := m.message.ConflationKey
+	if _, ok := c.pending[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.pending[key] = m
+}
+
+// flushConflated sends all the pending conflated messages to their subscribers.
+func (j *Joe) flushConflated() {
+	for done, c := range j.conflated {
+		delete(j.conflated, done)
+
+		client := j.subscribers[done].Client
+
+		var err error
+		for _, key := range c.keys {
+			if m := c.pending[key]; !expiredNow(m.message) {
+				if err = m.sendTo(client); err != nil {
+					break
+				}
+			}
+		}
+
+		if err == nil {
+			err = client.Flush()
+		}
+
+		if err != nil {
+			j.fail(done, err)
+		}
+	}
+}
+
+// fail removes the subscriber after a message couldn't be sent to it.
+func (j *Joe) fail(done subscriber, err error) {
+	j.observer.SendError(err)
+	done <- err
+	// Technically it would be possible to just send the error,
+	// as Subscribe would send an unsubscription signal. The problem
+	// is that if the j.message channel is ready together with j.unsubscription
+	// and j.message is picked we might send again to this now unsubscribed
+	// subscriber, which will cause issues (e.g. deadlock on done).
+	// This line here is the reason why we need to verify we actually
+	// have this subscriber in removeSubscriber above.
+	j.removeSubscriber(done)
+}
+
+// sendTo sends the message to the given client. If the client is an EncodedMessageWriter,
+// the message is encoded once and the encoding is reused for all subsequent clients.
+func (m *messageWithTopics) sendTo(w MessageWriter) error {
+	if ew, ok := w.(EncodedMessageWriter); ok {
+		if m.encoded == nil {
+			m.encoded = m.message.Encode()
+		}
+
+		return ew.SendEncoded(m.encoded)
+	}
+
+	return w.Send(m.message)
+}
+
+// putAll puts the messages into the replayer, replacing them with the
+// messages returned by it. Only the non-panic errors are returned.
+func putAll(msgs []messageWithTopics, replay *Replayer) error { //nolint:gocritic // intended
+	var errs []error
+
+	for i := range msgs {
+		if *replay == nil {
+			break
+		}
+
+		m, err := tryPut(msgs[i], replay)
+		if _, isPanic := err.(replayPanic); err != nil && !isPanic { //nolint:errorlint // it's our error
+			// NOTE(tmaxmax): We could return panic errors here but we'd have to expose
+			// the error type in order for this error to be handled. Let's not change
+			// the public errors for now. See also the other note below.
+			errs = append(errs, err)
+		} else if m != nil {
+			msgs[i].message = m
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return errors.Join(errs...)
+}
+
+func (j *Joe) snapshot(replay *Replayer) ProviderStats { //nolint:gocritic // intended
+	stats := ProviderStats{
+		TopicSubscribers: maps.Clone(j.topics),
+		Subscribers:      len(j.subscribers),
+		Published:        j.published,
+	}
+
+	if rs, err := tryStats(replay); err == nil {
+		stats.Replayer = rs
+	}
+
+	return stats
+}
+
+func tryStats(replay *Replayer) (rs *ReplayerStats, err error) { //nolint:gocritic // intended
+	defer handleReplayerPanic(replay, &err)
+
+	if sr, ok := (*replay).(StatsReplayer); ok {
+		s := sr.Stats()
+		return &s, nil
+	}
+
+	return nil, nil
+}
+
+func tryHistory(topic string, replay *Replayer) (msgs []*Message, err error) { //nolint:gocritic // intended
+	defer handleReplayerPanic(replay, &err)
+
+	hr, ok := (*replay).(HistoryReplayer)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	return hr.History(topic), nil
+}
+
+func tryPurge(topic string, replay *Replayer) (n int, err error) { //nolint:gocritic // intended
+	defer handleReplayerPanic(replay, &err)
+
+	hr, ok := (*replay).(HistoryReplayer)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+
+	return hr.Purge(topic), nil
+}
+
 func tryReplay(sub Subscription, replay *Replayer) (err error) { //nolint:gocritic // intended
 	defer handleReplayerPanic(replay, &err)
 
+	sub.Client = &replayWriter{next: sub.Client, filter: sub.Filter}
+
 	return (*replay).Replay(sub)
 }
 
@@ -295,6 +845,41 @@ func tryPut(msg messageWithTopics, replay *Replayer) (m *Message, err error) { /
 	return (*replay).Put(msg.message, msg.topics)
 }
 
+// replayWriter is the client messages are replayed to. It drops the messages
+// the subscription doesn't accept, so replayers don't have to check for them.
+type replayWriter struct {
+	next   MessageWriter
+	filter func(*Message) bool
+}
+
+func (w *replayWriter) accepts(m *Message) bool {
+	return !expiredNow(m) && (w.filter == nil || w.filter(m))
+}
+
+func (w *replayWriter) Send(m *Message) error {
+	if !w.accepts(m) {
+		return nil
+	}
+
+	return w.next.Send(m)
+}
+
+func (w *replayWriter) SendEncoded(m *EncodedMessage) error {
+	if !w.accepts(m.Message()) {
+		return nil
+	}
+
+	if ew, ok := w.next.(EncodedMessageWriter); ok {
+		return ew.SendEncoded(m)
+	}
+
+	return w.next.Send(m.Message())
+}
+
+func (w *replayWriter) Flush() error {
+	return w.next.Flush()
+}
+
 type replayPanic struct{}
 
 func (replayPanic) Error() string { return "replay provider panicked" }
@@ -314,14 +899,41 @@ func (j *Joe) init() {
 		j.message = make(chan publishedMessage)
 		j.subscription = make(chan subscription)
 		j.unsubscription = make(chan subscriber)
+		j.calls = make(chan func(*Replayer))
 		j.done = make(chan struct{})
 		j.closed = make(chan struct{})
 		j.subscribers = map[subscriber]Subscription{}
+		j.topics = map[string]int{}
+		j.conflated = map[subscriber]*conflated{}
+		j.dedupe.window = j.DedupeWindow
+		j.observer = j.Observer
+		if j.observer == nil {
+			j.observer = NopObserver{}
+		}
 
 		replay := j.Replayer
 		if replay == nil {
 			replay = noopReplayer{}
 		}
 		go j.start(replay)
+
+		j.instanceID = j.InstanceID
+		if j.instanceID == "" {
+			j.instanceID = newRandomID()
+		}
+
+		if j.Bridge != nil {
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-j.done
+				cancel()
+			}()
+			// If receiving fails, Joe goes on with only its own messages.
+			go func() {
+				if err := j.Bridge.Receive(ctx, j.receive); err != nil && j.Logger != nil {
+					j.Logger.Error("sse: bridge stopped receiving", "error", err)
+				}
+			}()
+		}
 	})
 }
@@ -372,3 +372,519 @@ func TestJoe_ClientContextCloseOnError(t *testing.T) {
 	// to be sent successfully.
 	tests.Equal(t, <-errch, nil, "unexpected subscribe error")
 }
+
+func TestJoe_PublishBatch(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(3, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin}
+	cleanupJoe(t, j)
+
+	tests.Equal(t, j.PublishBatch([]sse.Publication{{Message: msg(t, "hello", "")}}), sse.ErrNoTopic, "publications must have topics")
+
+	ctx, cancel := newMockContext(t)
+
+	var sent []string
+	flushes := 0
+	client := mockClient(func(m *sse.Message) error {
+		if m == nil {
+			flushes++
+		} else {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{Client: client, Topics: []string{"a"}})
+	}()
+	<-ctx.waitingOnDone
+
+	err = j.PublishBatch([]sse.Publication{
+		{Message: msg(t, "first", ""), Topics: []string{"a"}},
+		{Message: msg(t, "second", ""), Topics: []string{"b"}},
+		{Message: msg(t, "third", ""), Topics: []string{"a", "b"}},
+	})
+	tests.Equal(t, err, nil, "unexpected batch publish error")
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+
+	tests.DeepEqual(t, sent, []string{"id: 0\ndata: first\n\n", "id: 2\ndata: third\n\n"}, "invalid messages sent")
+	tests.Equal(t, flushes, 1, "batch should be flushed once")
+
+	var replayed []string
+	err = fin.Replay(sse.Subscription{
+		Client: mockClient(func(m *sse.Message) error {
+			if m != nil {
+				replayed = append(replayed, m.String())
+			}
+			return nil
+		}),
+		LastEventID: sse.ID("0"),
+		Topics:      []string{"b"},
+	})
+	tests.Equal(t, err, nil, "unexpected replay error")
+	tests.DeepEqual(t, replayed, []string{"id: 1\ndata: second\n\n", "id: 2\ndata: third\n\n"}, "all batch messages should be put in the replayer")
+}
+
+type encodedClient struct {
+	encoded chan *sse.EncodedMessage
+}
+
+func (e *encodedClient) Send(*sse.Message) error {
+	return errors.New("message should have been sent encoded")
+}
+
+func (e *encodedClient) SendEncoded(m *sse.EncodedMessage) error {
+	e.encoded <- m
+	return nil
+}
+
+func (e *encodedClient) Flush() error { return nil }
+
+func TestJoe_encodesOnce(t *testing.T) {
+	t.Parallel()
+
+	j := &sse.Joe{}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	clients := []*encodedClient{
+		{encoded: make(chan *sse.EncodedMessage, 1)},
+		{encoded: make(chan *sse.EncodedMessage, 1)},
+	}
+
+	for _, c := range clients {
+		ctx, _ := newMockContext(t)
+		go func() { _ = j.Subscribe(ctx, sse.Subscription{Client: c, Topics: topics}) }()
+		<-ctx.waitingOnDone
+	}
+
+	m := msg(t, "hello", "")
+	tests.Equal(t, j.Publish(m, topics), nil, "unexpected publish error")
+
+	a, b := <-clients[0].encoded, <-clients[1].encoded
+	tests.Expect(t, a == b, "message should be encoded once for all clients")
+	tests.Expect(t, a.Message() == m, "encoded message should be the published one")
+	tests.Equal(t, string(a.Bytes()), "data: hello\n\n", "invalid encoding")
+}
+
+func TestJoe_SubscriptionFilter(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(3, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	_ = j.Publish(msg(t, "first", ""), topics)
+	_ = j.Publish(&sse.Message{Type: sse.Type("private")}, topics)
+
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{
+			Client:      client,
+			Topics:      topics,
+			LastEventID: sse.ID("0"),
+			Filter: func(m *sse.Message) bool {
+				return m.Type.String() != "private"
+			},
+		})
+	}()
+	<-ctx.waitingOnDone
+
+	_ = j.Publish(&sse.Message{Type: sse.Type("private")}, topics)
+	_ = j.Publish(msg(t, "second", ""), topics)
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+	tests.DeepEqual(t, sent, []string{"id: 3\ndata: second\n\n"}, "filtered messages should not be sent")
+}
+
+// allReplayer replays all the messages it was given, without checking anything.
+type allReplayer struct {
+	messages []*sse.Message
+}
+
+func (a *allReplayer) Put(m *sse.Message, _ []string) (*sse.Message, error) {
+	a.messages = append(a.messages, m)
+	return m, nil
+}
+
+func (a *allReplayer) Replay(sub sse.Subscription) error {
+	for _, m := range a.messages {
+		if err := sub.Client.Send(m); err != nil {
+			return err
+		}
+	}
+
+	return sub.Client.Flush()
+}
+
+func TestJoe_SubscriptionFilter_replayer(t *testing.T) {
+	t.Parallel()
+
+	j := &sse.Joe{Replayer: &allReplayer{}}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	_ = j.Publish(msg(t, "first", "1"), topics)
+	_ = j.Publish(&sse.Message{ID: sse.ID("2"), Type: sse.Type("private")}, topics)
+
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{
+			Client:      client,
+			Topics:      topics,
+			LastEventID: sse.ID("0"),
+			Filter: func(m *sse.Message) bool {
+				return m.Type.String() != "private"
+			},
+		})
+	}()
+	<-ctx.waitingOnDone
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+	tests.DeepEqual(t, sent, []string{"id: 1\ndata: first\n\n"}, "Joe should not replay filtered messages")
+}
+
+func TestJoe_expiredReplay(t *testing.T) {
+	t.Parallel()
+
+	j := &sse.Joe{Replayer: &allReplayer{}}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	expired := msg(t, "expired", "1")
+	expired.Expiry = time.Now().Add(-time.Second)
+	_ = j.Publish(expired, topics)
+	_ = j.Publish(msg(t, "valid", "2"), topics)
+
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{Client: client, Topics: topics, LastEventID: sse.ID("0")})
+	}()
+	<-ctx.waitingOnDone
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+	tests.DeepEqual(t, sent, []string{"id: 2\ndata: valid\n\n"}, "Joe should not replay expired messages")
+}
+
+func TestJoe_Stats(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(2, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin}
+	cleanupJoe(t, j)
+
+	stats, err := j.Stats()
+	tests.Equal(t, err, nil, "unexpected stats error")
+	tests.DeepEqual(t, stats, sse.ProviderStats{
+		TopicSubscribers: map[string]int{},
+		Replayer:         &sse.ReplayerStats{Capacity: 2},
+	}, "invalid initial stats")
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{
+			Client: mockClient(func(*sse.Message) error { return nil }),
+			Topics: []string{"a", "b", "a"},
+		})
+	}()
+	<-ctx.waitingOnDone
+
+	for i := 0; i < 3; i++ {
+		_ = j.Publish(msg(t, "hello", ""), []string{"a"})
+	}
+
+	stats, err = j.Stats()
+	tests.Equal(t, err, nil, "unexpected stats error")
+	tests.DeepEqual(t, stats, sse.ProviderStats{
+		TopicSubscribers: map[string]int{"a": 1, "b": 1},
+		Replayer:         &sse.ReplayerStats{Buffered: 2, Capacity: 2},
+		Subscribers:      1,
+		Published:        3,
+	}, "invalid stats")
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+
+	_ = j.Shutdown(context.Background())
+	_, err = j.Stats()
+	tests.Equal(t, err, sse.ErrProviderClosed, "stats should not be available on closed joe")
+}
+
+func TestJoe_TopicLifecycle(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan string, 16)
+	j := &sse.Joe{
+		OnTopicActive:   func(topic string) { events <- "active " + topic },
+		OnTopicInactive: func(topic string) { events <- "inactive " + topic },
+	}
+
+	subscribeTopics := func(topics ...string) (context.CancelFunc, <-chan error) {
+		ctx, cancel := newMockContext(t)
+		done := make(chan error, 1)
+		go func() {
+			done <- j.Subscribe(ctx, sse.Subscription{
+				Client: mockClient(func(*sse.Message) error { return nil }),
+				Topics: topics,
+			})
+		}()
+		<-ctx.waitingOnDone
+		// Subscriptions are processed in order, so after Stats returns the subscription is active.
+		_, _ = j.Stats()
+		return cancel, done
+	}
+
+	cancelFirst, firstDone := subscribeTopics("a", "b", "a")
+	cancelSecond, secondDone := subscribeTopics("b")
+
+	cancelFirst()
+	<-firstDone
+	_, _ = j.Stats()
+
+	_, _ = subscribeTopics("c")
+	tests.Equal(t, j.Shutdown(context.Background()), nil, "unexpected shutdown error")
+	cancelSecond()
+	<-secondDone
+	close(events)
+
+	var got []string
+	for e := range events {
+		got = append(got, e)
+	}
+
+	tests.DeepEqual(t, got, []string{
+		"active a", "active b",
+		"inactive a",
+		"active c",
+		"inactive b", "inactive c",
+	}, "invalid topic lifecycle events")
+}
+
+func TestJoe_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(3, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+	_ = j.Publish(msg(t, "first", ""), topics)
+	_ = j.Publish(msg(t, "second", ""), topics)
+
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{
+			Client:      client,
+			Topics:      topics,
+			LastEventID: sse.ID("0"),
+			Filter: func(m *sse.Message) bool {
+				return m.Type.String() != "private"
+			},
+			Snapshot: func() []*sse.Message {
+				return []*sse.Message{msg(t, "state", ""), {Type: sse.Type("private")}}
+			},
+		})
+	}()
+	<-ctx.waitingOnDone
+
+	_ = j.Publish(msg(t, "third", ""), topics)
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+	tests.DeepEqual(t, sent, []string{
+		"id: 1\ndata: second\n\n",
+		"data: state\n\n",
+		"id: 2\ndata: third\n\n",
+	}, "snapshot should be sent after replay and before live messages")
+}
+
+func TestJoe_Conflation(t *testing.T) {
+	t.Parallel()
+
+	j := &sse.Joe{ConflationInterval: time.Millisecond * 20}
+	cleanupJoe(t, j)
+
+	flushed := make(chan []string, 2)
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		} else {
+			flushed <- sent
+			sent = nil
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{Client: client, Topics: []string{sse.DefaultTopic}})
+	}()
+	<-ctx.waitingOnDone
+
+	keyed := func(data, key string) *sse.Message {
+		m := msg(t, data, "")
+		m.ConflationKey = key
+		return m
+	}
+
+	_ = j.PublishBatch([]sse.Publication{
+		{Message: keyed("x1", "x"), Topics: []string{sse.DefaultTopic}},
+		{Message: keyed("y1", "y"), Topics: []string{sse.DefaultTopic}},
+		{Message: keyed("x2", "x"), Topics: []string{sse.DefaultTopic}},
+		{Message: msg(t, "plain", ""), Topics: []string{sse.DefaultTopic}},
+		{Message: keyed("x3", "x"), Topics: []string{sse.DefaultTopic}},
+	})
+
+	tests.DeepEqual(t, <-flushed, []string{"data: plain\n\n"}, "messages without key should be sent immediately")
+	tests.DeepEqual(t, <-flushed, []string{"data: x3\n\n", "data: y1\n\n"}, "only the newest message of each key should be sent")
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+}
+
+func TestJoe_Expiry(t *testing.T) {
+	t.Parallel()
+
+	j := &sse.Joe{}
+	cleanupJoe(t, j)
+
+	var sent []string
+	client := mockClient(func(m *sse.Message) error {
+		if m != nil {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	ctx, cancel := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{Client: client, Topics: []string{sse.DefaultTopic}})
+	}()
+	<-ctx.waitingOnDone
+
+	expired := msg(t, "typing", "")
+	expired.Expiry = time.Now().Add(-time.Millisecond)
+	_ = j.Publish(expired, []string{sse.DefaultTopic})
+
+	fresh := msg(t, "typing", "")
+	fresh.Expiry = time.Now().Add(time.Hour)
+	_ = j.Publish(fresh, []string{sse.DefaultTopic})
+
+	cancel()
+	tests.Equal(t, <-done, nil, "unexpected subscribe error")
+	tests.DeepEqual(t, sent, []string{"data: typing\n\n"}, "expired messages should not be sent")
+}
+
+func TestJoe_PublishOnce(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(5, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin, DedupeWindow: time.Millisecond * 20}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+
+	_, err = j.PublishOnce("a", msg(t, "a", ""), nil)
+	tests.Equal(t, err, sse.ErrNoTopic, "topics should be validated")
+
+	id, err := j.PublishOnce("a", msg(t, "a", ""), topics)
+	tests.Equal(t, err, nil, "unexpected publish error")
+	tests.Equal(t, id, sse.ID("0"), "invalid assigned ID")
+
+	id, err = j.PublishOnce("a", msg(t, "a", ""), topics)
+	tests.Equal(t, err, nil, "unexpected publish error")
+	tests.Equal(t, id, sse.ID("0"), "duplicates should return the original ID")
+
+	id, _ = j.PublishOnce("b", msg(t, "b", ""), topics)
+	tests.Equal(t, id, sse.ID("1"), "different keys should be published")
+
+	time.Sleep(j.DedupeWindow)
+
+	id, _ = j.PublishOnce("a", msg(t, "a", ""), topics)
+	tests.Equal(t, id, sse.ID("2"), "keys should be forgotten after the window")
+
+	var replayed []string
+	for _, m := range replay(t, fin, sse.ID("0")) {
+		replayed = append(replayed, m.ID.String())
+	}
+	tests.DeepEqual(t, replayed, []string{"1", "2"}, "duplicates should not be put into the replayer")
+}
+
+func TestJoe_PublishOnce_replayerError(t *testing.T) {
+	t.Parallel()
+
+	// Messages with IDs can't be put into an autoID replayer.
+	fin, err := sse.NewFiniteReplayer(5, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	j := &sse.Joe{Replayer: fin, DedupeWindow: time.Minute}
+	cleanupJoe(t, j)
+
+	topics := []string{sse.DefaultTopic}
+
+	id, err := j.PublishOnce("a", msg(t, "a", "7"), topics)
+	tests.Expect(t, err != nil, "replayer error should be returned")
+	tests.Equal(t, id, sse.ID("7"), "the message's ID should be returned")
+
+	id, err = j.PublishOnce("a", msg(t, "a", "8"), topics)
+	tests.Equal(t, err, nil, "unexpected publish error")
+	tests.Equal(t, id, sse.ID("7"), "duplicates should return the original ID")
+}
@@ -59,6 +59,19 @@ type Message struct {
 	ID    EventID
 	Type  EventType
 	Retry time.Duration
+	// ConflationKey identifies messages which supersede each other – for example, the
+	// price updates of a single instrument. It is never sent to clients.
+	// See Joe.ConflationInterval for more information.
+	ConflationKey string
+	// Expiry is the time after which the message is worthless – for example, a typing indicator.
+	// Joe doesn't send expired messages and replayers don't replay them, regardless of their own
+	// retention policy. It is never sent to clients. The message doesn't expire if it is zero.
+	Expiry time.Time
+}
+
+// Expired reports whether the message's Expiry is set and it is not after the given time.
+func (e *Message) Expired(now time.Time) bool {
+	return !e.Expiry.IsZero() && !e.Expiry.After(now)
 }
 
 func (e *Message) appendText(isComment bool, chunks ...string) {
@@ -234,6 +247,42 @@ func (e *Message) String() string {
 	return s.String()
 }
 
+// Encode serializes the message once to its standard textual representation.
+// The returned EncodedMessage can then be written to any number of clients
+// without serializing the message again.
+//
+// The message must not be modified after it is encoded, as the changes
+// won't be reflected in the encoded representation.
+func (e *Message) Encode() *EncodedMessage {
+	data, _ := e.MarshalText()
+	return &EncodedMessage{message: e, data: data}
+}
+
+// An EncodedMessage is a Message together with its standard textual
+// representation. Create one using the Message's Encode method.
+//
+// Encoded messages are immutable and safe for concurrent use.
+type EncodedMessage struct {
+	message *Message
+	data    []byte
+}
+
+// Message returns the message that was encoded. It must not be modified.
+func (e *EncodedMessage) Message() *Message {
+	return e.message
+}
+
+// Bytes returns the encoded message. The returned slice must not be modified.
+func (e *EncodedMessage) Bytes() []byte {
+	return e.data
+}
+
+// WriteTo writes the encoded message to the given writer using a single Write call.
+func (e *EncodedMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(e.data)
+	return int64(n), err
+}
+
 // UnmarshalError is the error returned by the Message's UnmarshalText method.
 // If the error is related to a specific field, FieldName will be a non-empty string.
 // If no fields were found in the target text or any other errors occurred, only
@@ -268,6 +317,8 @@ func (e *Message) reset() {
 	e.Type = EventType{}
 	e.ID = EventID{}
 	e.Retry = 0
+	e.ConflationKey = ""
+	e.Expiry = time.Time{}
 }
 
 // UnmarshalText extracts the first event found in the given byte slice into the
@@ -342,10 +393,12 @@ func (e *Message) Clone() *Message {
 	return &Message{
 		// The first AppendData will trigger a reallocation.
 		// Already appended chunks cannot be modified/removed, so this is safe.
-		chunks: e.chunks[:len(e.chunks):len(e.chunks)],
-		Retry:  e.Retry,
-		Type:   e.Type,
-		ID:     e.ID,
+		chunks:        e.chunks[:len(e.chunks):len(e.chunks)],
+		Retry:         e.Retry,
+		Type:          e.Type,
+		ID:            e.ID,
+		ConflationKey: e.ConflationKey,
+		Expiry:        e.Expiry,
 	}
 }
 
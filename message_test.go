@@ -87,6 +87,34 @@ func TestEvent_WriteTo(t *testing.T) {
 	}
 }
 
+type countingWriter struct {
+	strings.Builder
+	writes int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Builder.Write(p)
+}
+
+func TestMessage_Encode(t *testing.T) {
+	t.Parallel()
+
+	e := &Message{Type: Type("test_event"), ID: ID("example_id")}
+	e.AppendData("This is an example\nOf an event")
+
+	enc := e.Encode()
+	tests.Expect(t, enc.Message() == e, "encoded message should reference the original message")
+	tests.Equal(t, string(enc.Bytes()), e.String(), "invalid encoding")
+
+	w := &countingWriter{}
+	n, err := enc.WriteTo(w)
+	tests.Equal(t, err, nil, "unexpected write error")
+	tests.Equal(t, n, int64(len(enc.Bytes())), "invalid written byte count")
+	tests.Equal(t, w.writes, 1, "encoded message should be written at once")
+	tests.Equal(t, w.String(), e.String(), "invalid output")
+}
+
 func TestEvent_UnmarshalText(t *testing.T) {
 	t.Parallel()
 
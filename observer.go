@@ -0,0 +1,180 @@
+package sse
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// An Observer is notified of the important events in the lifecycle of sessions, providers
+// and replayers, so that they can be instrumented. It is used by the Server, Joe, Session,
+// FiniteReplayer and ValidReplayer, each of which has an Observer field.
+//
+// Observers are required to be thread-safe and their methods are called synchronously,
+// so they should return as fast as possible. Embed NopObserver to implement only
+// some of the methods.
+type Observer interface {
+	// SessionStarted is called by the Server when a session is subscribed to the provider.
+	SessionStarted(r *http.Request)
+	// SessionEnded is called by the Server when a started session ends, together with
+	// the error which ended it, if any.
+	SessionEnded(r *http.Request, err error)
+	// SessionRejected is called by the Server when a session is refused, together with
+	// the status code of the response.
+	SessionRejected(r *http.Request, status int)
+	// MessagePublished is called by providers for each published message.
+	MessagePublished(m *Message, topics []string)
+	// SendError is called by providers when sending messages to a client fails.
+	SendError(err error)
+	// Replayed is called by replayers when a subscription with a last event ID is replayed.
+	// The hit flag reports whether the last event ID was found, and count is
+	// the number of replayed messages.
+	Replayed(hit bool, count int)
+	// BytesWritten is called by sessions after writing data to the client.
+	BytesWritten(n int)
+}
+
+// NopObserver is an Observer which does nothing. Embed it in your own
+// observers to implement only the methods you need.
+type NopObserver struct{}
+
+// SessionStarted implements Observer.
+func (NopObserver) SessionStarted(*http.Request) {}
+
+// SessionEnded implements Observer.
+func (NopObserver) SessionEnded(*http.Request, error) {}
+
+// SessionRejected implements Observer.
+func (NopObserver) SessionRejected(*http.Request, int) {}
+
+// MessagePublished implements Observer.
+func (NopObserver) MessagePublished(*Message, []string) {}
+
+// SendError implements Observer.
+func (NopObserver) SendError(error) {}
+
+// Replayed implements Observer.
+func (NopObserver) Replayed(bool, int) {}
+
+// BytesWritten implements Observer.
+func (NopObserver) BytesWritten(int) {}
+
+// ExpvarObserver is an Observer which records counters for all the observed events
+// and exposes them as an expvar.Map. The counters can also be rendered in the
+// Prometheus text exposition format, using the handler returned by PrometheusHandler.
+//
+// The exposed variables are:
+//
+//	sessions_active          – the number of active sessions
+//	sessions_started_total   – the number of started sessions
+//	sessions_rejected_total  – the number of refused sessions
+//	messages_published_total – the number of published messages
+//	send_errors_total        – the number of failed sends to clients
+//	replay_hits_total        – the number of replays for which the last event ID was found
+//	replay_misses_total      – the number of replays for which the last event ID was not found
+//	messages_replayed_total  – the number of replayed messages
+//	bytes_written_total      – the number of bytes written to clients
+type ExpvarObserver struct {
+	vars *expvar.Map
+
+	sessionsActive    expvar.Int
+	sessionsStarted   expvar.Int
+	sessionsRejected  expvar.Int
+	messagesPublished expvar.Int
+	sendErrors        expvar.Int
+	replayHits        expvar.Int
+	replayMisses      expvar.Int
+	messagesReplayed  expvar.Int
+	bytesWritten      expvar.Int
+}
+
+type observerMetric struct {
+	name string
+	help string
+	typ  string
+	v    func(o *ExpvarObserver) *expvar.Int
+}
+
+var observerMetrics = [...]observerMetric{
+	{"sessions_active", "Number of active sessions.", "gauge", func(o *ExpvarObserver) *expvar.Int { return &o.sessionsActive }},
+	{"sessions_started_total", "Number of started sessions.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.sessionsStarted }},
+	{"sessions_rejected_total", "Number of refused sessions.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.sessionsRejected }},
+	{"messages_published_total", "Number of published messages.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.messagesPublished }},
+	{"send_errors_total", "Number of failed sends to clients.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.sendErrors }},
+	{"replay_hits_total", "Number of replays for which the last event ID was found.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.replayHits }},
+	{"replay_misses_total", "Number of replays for which the last event ID was not found.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.replayMisses }},
+	{"messages_replayed_total", "Number of replayed messages.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.messagesReplayed }},
+	{"bytes_written_total", "Number of bytes written to clients.", "counter", func(o *ExpvarObserver) *expvar.Int { return &o.bytesWritten }},
+}
+
+// NewExpvarObserver creates an ExpvarObserver. If the name is not empty, the variables
+// are published using expvar under the given name – just like with expvar.Publish,
+// the function panics if the name is already used. Otherwise, they are not published
+// and can be retrieved using the Vars method.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	o := &ExpvarObserver{vars: new(expvar.Map)}
+	for _, m := range observerMetrics {
+		o.vars.Set(m.name, m.v(o))
+	}
+
+	if name != "" {
+		expvar.Publish(name, o.vars)
+	}
+
+	return o
+}
+
+// Vars returns the map containing the observer's variables.
+func (o *ExpvarObserver) Vars() *expvar.Map { return o.vars }
+
+// SessionStarted implements Observer.
+func (o *ExpvarObserver) SessionStarted(*http.Request) {
+	o.sessionsActive.Add(1)
+	o.sessionsStarted.Add(1)
+}
+
+// SessionEnded implements Observer.
+func (o *ExpvarObserver) SessionEnded(*http.Request, error) { o.sessionsActive.Add(-1) }
+
+// SessionRejected implements Observer.
+func (o *ExpvarObserver) SessionRejected(*http.Request, int) { o.sessionsRejected.Add(1) }
+
+// MessagePublished implements Observer.
+func (o *ExpvarObserver) MessagePublished(*Message, []string) { o.messagesPublished.Add(1) }
+
+// SendError implements Observer.
+func (o *ExpvarObserver) SendError(error) { o.sendErrors.Add(1) }
+
+// Replayed implements Observer.
+func (o *ExpvarObserver) Replayed(hit bool, count int) {
+	if hit {
+		o.replayHits.Add(1)
+	} else {
+		o.replayMisses.Add(1)
+	}
+	o.messagesReplayed.Add(int64(count))
+}
+
+// BytesWritten implements Observer.
+func (o *ExpvarObserver) BytesWritten(n int) { o.bytesWritten.Add(int64(n)) }
+
+// PrometheusHandler returns a handler which renders the observer's counters in the
+// Prometheus text exposition format. The metric names are prefixed with the given
+// namespace and an underscore, if the namespace is not empty.
+func (o *ExpvarObserver) PrometheusHandler(namespace string) http.Handler {
+	prefix := ""
+	if namespace != "" {
+		prefix = namespace + "_"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		for _, m := range observerMetrics {
+			name := prefix + m.name
+			_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, m.help, name, m.typ, name, m.v(o).Value())
+		}
+	})
+}
+
+var _ Observer = (*ExpvarObserver)(nil)
@@ -0,0 +1,103 @@
+package sse_test
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func expvarValue(tb testing.TB, o *sse.ExpvarObserver, name string) string {
+	tb.Helper()
+
+	v := o.Vars().Get(name)
+	tests.Expect(tb, v != nil, "variable %q should exist", name)
+
+	return v.String()
+}
+
+var expvarTestRuns atomic.Int64
+
+func TestExpvarObserver(t *testing.T) {
+	t.Parallel()
+
+	// expvar names are process-global, so each run of the test needs a unique one.
+	name := fmt.Sprintf("%s-%d", t.Name(), expvarTestRuns.Add(1))
+	published := sse.NewExpvarObserver(name)
+	tests.Expect(t, expvar.Get(name) == published.Vars(), "variables should be published")
+
+	o := sse.NewExpvarObserver("")
+
+	rec := httptest.NewRecorder()
+	req, cancel := request(t, "", "/", http.NoBody)
+	go cancel()
+
+	(&sse.Server{Provider: newMockProvider(t, nil), Observer: o}).ServeHTTP(rec, req)
+	(&sse.Server{
+		Observer: o,
+		AcceptSession: func(*http.Request) ([]string, error) {
+			return nil, &sse.RejectionError{}
+		},
+	}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", http.NoBody))
+
+	r, err := sse.NewValidReplayer(time.Minute, true)
+	tests.Equal(t, err, nil, "unexpected NewValidReplayer error")
+	r.Observer = o
+
+	_, _ = r.Put(&sse.Message{}, []string{sse.DefaultTopic})
+	_, _ = r.Put(&sse.Message{}, []string{sse.DefaultTopic})
+	_ = replay(t, r, sse.ID("0"))
+
+	expected := map[string]string{
+		"sessions_active":         "0",
+		"sessions_started_total":  "1",
+		"sessions_rejected_total": "1",
+		"bytes_written_total":     "13",
+		"replay_hits_total":       "1",
+		// replay tries "mama" and "10" too.
+		"replay_misses_total":     "2",
+		"messages_replayed_total": "1",
+	}
+	for name, value := range expected {
+		tests.Equal(t, expvarValue(t, o, name), value, "invalid value for %q", name)
+	}
+
+	rec = httptest.NewRecorder()
+	o.PrometheusHandler("sse").ServeHTTP(rec, httptest.NewRequest("", "/metrics", http.NoBody))
+
+	tests.Equal(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8", "invalid content type")
+	body := rec.Body.String()
+	tests.Expect(t, strings.Contains(body, "# HELP sse_sessions_active Number of active sessions.\n# TYPE sse_sessions_active gauge\nsse_sessions_active 0\n"), "invalid gauge exposition:\n%s", body)
+	tests.Expect(t, strings.Contains(body, "# TYPE sse_bytes_written_total counter\nsse_bytes_written_total 13\n"), "invalid counter exposition:\n%s", body)
+}
+
+func TestJoe_Observer(t *testing.T) {
+	t.Parallel()
+
+	o := sse.NewExpvarObserver("")
+	j := &sse.Joe{Observer: o}
+	cleanupJoe(t, j)
+
+	ctx, _ := newMockContext(t)
+	done := make(chan error)
+	go func() {
+		done <- j.Subscribe(ctx, sse.Subscription{
+			Client: mockClient(func(*sse.Message) error { return errWriteFailed }),
+			Topics: []string{sse.DefaultTopic},
+		})
+	}()
+	<-ctx.waitingOnDone
+
+	tests.Equal(t, j.Publish(&sse.Message{}, []string{sse.DefaultTopic}), nil, "unexpected publish error")
+	tests.ErrorIs(t, <-done, errWriteFailed, "send error should be returned")
+
+	tests.Equal(t, expvarValue(t, o, "messages_published_total"), "1", "invalid published count")
+	tests.Equal(t, expvarValue(t, o, "send_errors_total"), "1", "invalid send errors count")
+}
@@ -0,0 +1,192 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// A ProviderMiddleware wraps a Provider so that the calls to its methods can be
+// intercepted – for logging, metrics, auditing or validation, for example.
+//
+// The returned Provider must call the next one to actually execute the operations.
+// It should also implement BatchPublisher, so that batches are not split into
+// separate publishes, IdempotentPublisher, forwarding the call to the next provider
+// using the same method, and have an Unwrap method returning the next Provider,
+// so that other optional features of the wrapped providers can be accessed.
+type ProviderMiddleware func(next Provider) Provider
+
+// ChainProvider wraps the given provider with the middlewares. The first middleware
+// is the outermost one, so it is the first to intercept each call.
+func ChainProvider(p Provider, middlewares ...ProviderMiddleware) Provider {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		p = middlewares[i](p)
+	}
+
+	return p
+}
+
+// LogProvider creates a middleware which logs all the operations of the provider
+// using the given logger. Successful operations are logged at debug level, failures
+// at error level.
+func LogProvider(l *slog.Logger) ProviderMiddleware {
+	return func(next Provider) Provider {
+		return &logProvider{next: next, l: l}
+	}
+}
+
+type logProvider struct {
+	next Provider
+	l    *slog.Logger
+}
+
+func (p *logProvider) Subscribe(ctx context.Context, sub Subscription) error {
+	p.l.DebugContext(ctx, "sse: provider subscribe", "topics", sub.Topics, "lastEventID", sub.LastEventID)
+
+	err := p.next.Subscribe(ctx, sub)
+	if err != nil {
+		p.l.ErrorContext(ctx, "sse: provider subscription failed", "topics", sub.Topics, "error", err)
+	} else {
+		p.l.DebugContext(ctx, "sse: provider subscription ended", "topics", sub.Topics)
+	}
+
+	return err
+}
+
+func (p *logProvider) Publish(m *Message, topics []string) error {
+	err := p.next.Publish(m, topics)
+	p.logPublish(m, topics, err)
+
+	return err
+}
+
+func (p *logProvider) PublishBatch(batch []Publication) error {
+	err := publishBatch(p.next, batch)
+	if err != nil {
+		p.l.Error("sse: provider batch publish failed", "count", len(batch), "error", err)
+	} else {
+		p.l.Debug("sse: provider batch publish", "count", len(batch))
+	}
+
+	return err
+}
+
+func (p *logProvider) PublishOnce(key string, m *Message, topics []string) (EventID, error) {
+	id, err := publishOnce(p.next, key, m, topics)
+	if err != nil {
+		p.l.Error("sse: provider publish once failed", "key", key, "type", m.Type, "topics", topics, "error", err)
+	} else {
+		p.l.Debug("sse: provider publish once", "key", key, "id", id, "type", m.Type, "topics", topics)
+	}
+
+	return id, err
+}
+
+func (p *logProvider) logPublish(m *Message, topics []string, err error) {
+	if err != nil {
+		p.l.Error("sse: provider publish failed", "id", m.ID, "type", m.Type, "topics", topics, "error", err)
+	} else {
+		p.l.Debug("sse: provider publish", "id", m.ID, "type", m.Type, "topics", topics)
+	}
+}
+
+func (p *logProvider) Shutdown(ctx context.Context) error {
+	err := p.next.Shutdown(ctx)
+	if err != nil {
+		p.l.ErrorContext(ctx, "sse: provider shutdown failed", "error", err)
+	} else {
+		p.l.DebugContext(ctx, "sse: provider shutdown")
+	}
+
+	return err
+}
+
+func (p *logProvider) Unwrap() Provider { return p.next }
+
+// ValidatePublish creates a middleware which validates each message before it is published.
+// If the validation function returns an error, the message is not published and the error
+// is returned to the publisher. A batch is published only if all its messages are valid.
+func ValidatePublish(validate func(m *Message, topics []string) error) ProviderMiddleware {
+	return func(next Provider) Provider {
+		return &validatingProvider{Provider: next, validate: validate}
+	}
+}
+
+type validatingProvider struct {
+	Provider
+	validate func(*Message, []string) error
+}
+
+func (p *validatingProvider) Publish(m *Message, topics []string) error {
+	if err := p.validate(m, topics); err != nil {
+		return err
+	}
+
+	return p.Provider.Publish(m, topics)
+}
+
+func (p *validatingProvider) PublishBatch(batch []Publication) error {
+	for _, pub := range batch {
+		if err := p.validate(pub.Message, pub.Topics); err != nil {
+			return err
+		}
+	}
+
+	return publishBatch(p.Provider, batch)
+}
+
+func (p *validatingProvider) PublishOnce(key string, m *Message, topics []string) (EventID, error) {
+	if err := p.validate(m, topics); err != nil {
+		return EventID{}, err
+	}
+
+	return publishOnce(p.Provider, key, m, topics)
+}
+
+func (p *validatingProvider) Unwrap() Provider { return p.Provider }
+
+// publishBatch publishes the batch using the provider's PublishBatch method, if it
+// has one, or by publishing each message otherwise.
+func publishBatch(p Provider, batch []Publication) error {
+	if bp, ok := p.(BatchPublisher); ok {
+		return bp.PublishBatch(batch)
+	}
+
+	var errs []error
+	for _, pub := range batch {
+		if err := p.Publish(pub.Message, pub.Topics); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// publishOnce publishes the message using the provider's PublishOnce method.
+// It returns errors.ErrUnsupported if the provider doesn't have one.
+func publishOnce(p Provider, key string, m *Message, topics []string) (EventID, error) {
+	ip, ok := p.(IdempotentPublisher)
+	if !ok {
+		return EventID{}, errors.ErrUnsupported
+	}
+
+	return ip.PublishOnce(key, m, topics)
+}
+
+// findProvider returns the first provider in the chain of wrapped providers
+// which implements the given interface.
+func findProvider[T any](p Provider) (T, bool) {
+	for {
+		if v, ok := p.(T); ok {
+			return v, true
+		}
+
+		u, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			var zero T
+			return zero, false
+		}
+
+		p = u.Unwrap()
+	}
+}
@@ -0,0 +1,85 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+type recordCalls struct {
+	sse.Provider
+	calls *[]string
+	name  string
+}
+
+func (r *recordCalls) Publish(m *sse.Message, topics []string) error {
+	*r.calls = append(*r.calls, r.name)
+	return r.Provider.Publish(m, topics)
+}
+
+func TestChainProvider(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	record := func(name string) sse.ProviderMiddleware {
+		return func(next sse.Provider) sse.Provider {
+			return &recordCalls{Provider: next, calls: &calls, name: name}
+		}
+	}
+
+	p := &recordingProvider{}
+	s := &sse.Server{Provider: p, ProviderMiddleware: []sse.ProviderMiddleware{record("first"), record("second")}}
+
+	tests.Equal(t, s.Publish(&sse.Message{}), nil, "unexpected publish error")
+	tests.DeepEqual(t, calls, []string{"first", "second"}, "middlewares should be called in order")
+	tests.Equal(t, len(p.published), 1, "message should be published")
+}
+
+func TestLogProvider(t *testing.T) {
+	t.Parallel()
+
+	sb := &strings.Builder{}
+	l := slog.New(mockHandler{slog.NewTextHandler(sb, &slog.HandlerOptions{Level: slog.LevelDebug})})
+
+	p := sse.ChainProvider(&recordingProvider{}, sse.LogProvider(l))
+
+	tests.Equal(t, p.Publish(&sse.Message{ID: sse.ID("1")}, []string{"a"}), nil, "unexpected publish error")
+	tests.Equal(t, p.(sse.BatchPublisher).PublishBatch([]sse.Publication{{Message: &sse.Message{}, Topics: []string{"a"}}}), nil, "unexpected publish error")
+	tests.Equal(t, p.Shutdown(context.Background()), nil, "unexpected shutdown error")
+
+	expected := `level=DEBUG msg="sse: provider publish" id=1 type="" topics=[a]
+level=DEBUG msg="sse: provider batch publish" count=1
+level=DEBUG msg="sse: provider shutdown"
+`
+	tests.Equal(t, sb.String(), expected, "invalid log output")
+}
+
+func TestValidatePublish(t *testing.T) {
+	t.Parallel()
+
+	errInvalid := errors.New("message has no ID")
+	validate := sse.ValidatePublish(func(m *sse.Message, _ []string) error {
+		if !m.ID.IsSet() {
+			return errInvalid
+		}
+		return nil
+	})
+
+	rp := &recordingProvider{}
+	p := sse.ChainProvider(rp, validate)
+
+	tests.ErrorIs(t, p.Publish(&sse.Message{}, []string{"a"}), errInvalid, "invalid message should be rejected")
+	tests.Equal(t, p.Publish(&sse.Message{ID: sse.ID("1")}, []string{"a"}), nil, "valid message should be published")
+
+	err := p.(sse.BatchPublisher).PublishBatch([]sse.Publication{
+		{Message: &sse.Message{ID: sse.ID("2")}, Topics: []string{"a"}},
+		{Message: &sse.Message{}, Topics: []string{"a"}},
+	})
+	tests.ErrorIs(t, err, errInvalid, "batch with invalid messages should be rejected")
+	tests.Equal(t, len(rp.published), 1, "only the valid message should be published")
+}
@@ -0,0 +1,398 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/tmaxmax/go-sse/internal/hub"
+)
+
+// ErrHubDisconnected is returned by the RemoteProvider's Subscribe and Publish
+// methods when the connection to the hub is lost or the hub is shut down.
+var ErrHubDisconnected = errors.New("go-sse.hub: disconnected from hub")
+
+// ErrSlowSubscription is returned by the RemoteProvider's Subscribe method when the
+// subscription's client doesn't keep up with the messages received from the hub.
+var ErrSlowSubscription = errors.New("go-sse.hub: subscription can't keep up with the hub")
+
+// remoteQueueSize is the number of frames queued for each subscription of a RemoteProvider.
+const remoteQueueSize = 512
+
+// RemoteProvider is a Provider which uses the provider of a Hub running in another
+// process. Messages published by any process connected to the hub are sent to the
+// subscribers of all the processes, and all the processes share the hub's replayer.
+//
+// The connection to the hub is opened when the provider is first used. If it is lost,
+// all the subscriptions end with ErrHubDisconnected and the connection is opened again
+// on the next use. The clients of the Server then reconnect and, if the hub's provider
+// has a replayer, they receive the messages they missed.
+//
+// Subscription filters and snapshots are handled by the RemoteProvider, so they
+// work exactly as with the other providers. Each subscription's messages are queued
+// and sent to its client by the goroutine which called Subscribe, so a slow client
+// doesn't hold up the others. If its queue fills up, the subscription ends with
+// ErrSlowSubscription and the client catches up by reconnecting.
+type RemoteProvider struct {
+	// Dial opens the connection to the hub. If nil, net.Dialer.DialContext is used.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// The network of the hub, as accepted by net.Dial – for example, "unix" or "tcp".
+	// Defaults to "unix".
+	Network string
+	// The address of the hub – for example, the path of a Unix domain socket.
+	Address string
+
+	conn   *remoteConn
+	mu     sync.Mutex
+	closed bool
+}
+
+// connect returns the connection to the hub, opening it if necessary.
+func (r *RemoteProvider) connect(ctx context.Context) (*remoteConn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrProviderClosed
+	}
+
+	if r.conn != nil {
+		select {
+		case <-r.conn.done:
+		default:
+			return r.conn, nil
+		}
+	}
+
+	network := r.Network
+	if network == "" {
+		network = "unix"
+	}
+
+	dial := r.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	c, err := dial(ctx, network, r.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	r.conn = &remoteConn{
+		conn:    hub.NewConn(c),
+		subs:    map[uint64]*remoteSub{},
+		results: map[uint64]chan error{},
+		done:    make(chan struct{}),
+	}
+	go r.conn.run()
+
+	return r.conn, nil
+}
+
+// Subscribe subscribes to the hub's provider. It returns ErrHubDisconnected if the
+// connection to the hub is lost, and ErrProviderClosed if the provider is shut down.
+func (r *RemoteProvider) Subscribe(ctx context.Context, sub Subscription) error {
+	c, err := r.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	rs := &remoteSub{sub: sub, frames: make(chan *hub.Frame, remoteQueueSize), done: make(chan error, 1)}
+
+	id, err := c.add(rs)
+	if err != nil {
+		return err
+	}
+
+	f := &hub.Frame{Kind: hub.KindSubscribe, ID: id, Topics: sub.Topics}
+	if sub.LastEventID.IsSet() {
+		lastEventID := sub.LastEventID.String()
+		f.LastEventID = &lastEventID
+	}
+
+	if err = c.conn.Write(f); err != nil {
+		c.remove(id)
+		return err
+	}
+
+	for {
+		select {
+		case f := <-rs.frames:
+			if err = rs.handle(f); err == nil {
+				continue
+			}
+		case err = <-rs.done:
+			return err
+		case <-ctx.Done():
+			err = nil
+		}
+
+		rs.end(err)
+		c.remove(id)
+		// If this fails the connection is lost, so the hub ends the subscription anyway.
+		_ = c.conn.Write(&hub.Frame{Kind: hub.KindUnsubscribe, ID: id})
+
+		return err
+	}
+}
+
+// Publish publishes the message through the hub. It returns after the hub's provider
+// published the message, with the error returned by it, if any.
+func (r *RemoteProvider) Publish(m *Message, topics []string) error {
+	if len(topics) == 0 {
+		return ErrNoTopic
+	}
+
+	c, err := r.connect(context.Background())
+	if err != nil {
+		return err
+	}
+
+	id, res, err := c.await()
+	if err != nil {
+		return err
+	}
+
+	if err = c.conn.Write(&hub.Frame{Kind: hub.KindPublish, ID: id, Topics: topics, Message: toHubMessage(m)}); err != nil {
+		c.fail(id)
+		return err
+	}
+
+	return <-res
+}
+
+// Shutdown closes the connection to the hub, ending all the subscriptions with ErrProviderClosed.
+// The hub and the subscriptions of other processes are not affected.
+func (r *RemoteProvider) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrProviderClosed
+	}
+	r.closed = true
+	c := r.conn
+	r.mu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+
+	c.close(ErrProviderClosed)
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// remoteConn is a connection to the hub. Its subscriptions and pending publishes are
+// identified by IDs unique to the connection.
+type remoteConn struct {
+	conn    *hub.Conn
+	subs    map[uint64]*remoteSub
+	results map[uint64]chan error
+	done    chan struct{}
+	// The error the subscriptions and publishes fail with after the connection is closed.
+	err    error
+	lastID uint64
+	mu     sync.Mutex
+}
+
+func (c *remoteConn) add(rs *remoteSub) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	c.lastID++
+	c.subs[c.lastID] = rs
+
+	return c.lastID, nil
+}
+
+func (c *remoteConn) remove(id uint64) {
+	c.mu.Lock()
+	delete(c.subs, id)
+	c.mu.Unlock()
+}
+
+func (c *remoteConn) sub(id uint64) *remoteSub {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.subs[id]
+}
+
+// await registers a publish, returning the channel its result is sent on.
+func (c *remoteConn) await() (uint64, <-chan error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return 0, nil, c.err
+	}
+
+	c.lastID++
+	res := make(chan error, 1)
+	c.results[c.lastID] = res
+
+	return c.lastID, res, nil
+}
+
+// fail removes a publish which couldn't be sent.
+func (c *remoteConn) fail(id uint64) {
+	c.mu.Lock()
+	delete(c.results, id)
+	c.mu.Unlock()
+}
+
+func (c *remoteConn) resolve(id uint64, err error) {
+	c.mu.Lock()
+	res := c.results[id]
+	delete(c.results, id)
+	c.mu.Unlock()
+
+	if res != nil {
+		res <- err
+	}
+}
+
+// close closes the connection, ending the subscriptions and the pending publishes with the given error.
+func (c *remoteConn) close(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	subs, results := c.subs, c.results
+	c.subs, c.results = nil, nil
+	c.mu.Unlock()
+
+	_ = c.conn.Close()
+
+	for _, rs := range subs {
+		rs.end(err)
+	}
+	for _, res := range results {
+		res <- err
+	}
+}
+
+func (c *remoteConn) run() {
+	defer close(c.done)
+
+	for {
+		var f hub.Frame
+		if err := c.conn.Read(&f); err != nil {
+			c.close(ErrHubDisconnected)
+			return
+		}
+
+		if f.Kind == hub.KindPublished {
+			c.resolve(f.ID, frameError(&f))
+			continue
+		}
+
+		rs := c.sub(f.ID)
+		if rs == nil {
+			// The subscription ended in the meantime.
+			continue
+		}
+
+		switch f.Kind {
+		case hub.KindSubscribed, hub.KindMessage, hub.KindFlush:
+			if rs.queue(&f) {
+				continue
+			}
+
+			c.remove(f.ID)
+			rs.end(ErrSlowSubscription)
+			// Writing here could block reading, so the hub could block while sending to us.
+			go func(id uint64) { _ = c.conn.Write(&hub.Frame{Kind: hub.KindUnsubscribe, ID: id}) }(f.ID)
+		case hub.KindClosed:
+			c.remove(f.ID)
+			rs.end(frameError(&f))
+		default:
+		}
+	}
+}
+
+// remoteSub is a subscription to the hub. The connection's goroutine queues its frames,
+// which are handled by the goroutine which called Subscribe until the subscription ends.
+type remoteSub struct {
+	sub    Subscription
+	frames chan *hub.Frame
+	done   chan error
+	mu     sync.Mutex
+	ended  bool
+}
+
+// queue queues the frame, reporting whether there was room for it.
+func (s *remoteSub) queue(f *hub.Frame) bool {
+	select {
+	case s.frames <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *remoteSub) handle(f *hub.Frame) error {
+	switch f.Kind {
+	case hub.KindSubscribed:
+		return sendSnapshot(s.sub)
+	case hub.KindMessage:
+		if f.Message == nil {
+			return nil
+		}
+
+		m, err := fromHubMessage(f.Message)
+		if err != nil {
+			return err
+		}
+		if s.sub.Filter != nil && !s.sub.Filter(m) {
+			return nil
+		}
+
+		return s.sub.Client.Send(m)
+	case hub.KindFlush:
+		return s.sub.Client.Flush()
+	default:
+		return nil
+	}
+}
+
+// end ends the subscription, if it didn't already end, returning the given error from Subscribe.
+func (s *remoteSub) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	s.ended = true
+	s.done <- err
+}
+
+func frameError(f *hub.Frame) error {
+	if f.Error == "" {
+		return nil
+	}
+
+	switch f.Code {
+	case hub.CodeProviderClosed:
+		return ErrHubDisconnected
+	case hub.CodeNoTopic:
+		return ErrNoTopic
+	default:
+		return errors.New(f.Error)
+	}
+}
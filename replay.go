@@ -2,6 +2,7 @@ package sse
 
 import (
 	"errors"
+	"slices"
 	"strconv"
 	"time"
 )
@@ -33,6 +34,9 @@ func NewFiniteReplayer(
 // FiniteReplayer is a replayer that replays at maximum a certain number of events.
 // The events must have an ID unless the replayer is configured to set IDs automatically.
 type FiniteReplayer struct {
+	// An optional observer which is notified of replay hits and misses.
+	Observer Observer
+
 	currentID *uint64
 	buf       queue[messageWithTopics]
 }
@@ -55,21 +59,29 @@ func (f *FiniteReplayer) Put(message *Message, topics []string) (*Message, error
 }
 
 // Replay replays the stored messages to the listener.
+// Each stored message is encoded at most once, the first time it is replayed
+// to an EncodedMessageWriter.
 func (f *FiniteReplayer) Replay(subscription Subscription) error {
 	i := findIDInQueue(&f.buf, subscription.LastEventID, f.currentID != nil)
 	if i < 0 {
+		observeReplay(f.Observer, subscription, false, 0)
 		return nil
 	}
 
+	now := time.Now()
+
 	var err error
-	f.buf.each(i)(func(_ int, m messageWithTopics) bool {
-		if topicsIntersect(subscription.Topics, m.topics) {
-			if err = subscription.Client.Send(m.message); err != nil {
+	count := 0
+	f.buf.each(i)(func(j int, m messageWithTopics) bool {
+		if !m.message.Expired(now) && subscription.accepts(m.message, m.topics) {
+			if err = f.buf.buf[j].sendTo(subscription.Client); err != nil {
 				return false
 			}
+			count++
 		}
 		return true
 	})
+	observeReplay(f.Observer, subscription, true, count)
 	if err != nil {
 		return err
 	}
@@ -77,6 +89,49 @@ func (f *FiniteReplayer) Replay(subscription Subscription) error {
 	return subscription.Client.Flush()
 }
 
+// Stats returns the number of buffered messages and the maximum number of messages.
+func (f *FiniteReplayer) Stats() ReplayerStats {
+	return ReplayerStats{Buffered: f.buf.count, Capacity: len(f.buf.buf)}
+}
+
+// History returns the stored non-expired messages of the given topic, oldest first.
+func (f *FiniteReplayer) History(topic string) []*Message {
+	if f.buf.count == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	var msgs []*Message
+	f.buf.each(f.buf.head)(func(_ int, m messageWithTopics) bool {
+		if !m.message.Expired(now) && slices.Contains(m.topics, topic) {
+			msgs = append(msgs, m.message)
+		}
+		return true
+	})
+
+	return msgs
+}
+
+// Purge removes the given topic from the stored messages, so they aren't replayed to its
+// subscribers anymore. Messages which remain without topics keep their place in the buffer
+// until they are evicted, so that the IDs of the other messages can still be found.
+func (f *FiniteReplayer) Purge(topic string) int {
+	if f.buf.count == 0 {
+		return 0
+	}
+
+	n := 0
+	f.buf.each(f.buf.head)(func(j int, _ messageWithTopics) bool {
+		if f.buf.buf[j].removeTopic(topic) {
+			n++
+		}
+		return true
+	})
+
+	return n
+}
+
 // ValidReplayer is a Replayer that replays all the buffered non-expired events.
 //
 // The replayer removes any expired events when a new event is put and after at least
@@ -101,6 +156,9 @@ type ValidReplayer struct {
 	// it to 0 – this disables automatic cleanup, enabling you to do it manually
 	// using the GC method.
 	GCInterval time.Duration
+
+	// An optional observer which is notified of replay hits and misses.
+	Observer Observer
 }
 
 // NewValidReplayer creates a ValidReplayer with the given message
@@ -190,24 +248,73 @@ func (v *ValidReplayer) doGC(now time.Time) {
 	}
 }
 
+// Stats returns the number of buffered messages. Expired messages which were not
+// yet removed are counted too.
+func (v *ValidReplayer) Stats() ReplayerStats {
+	return ReplayerStats{Buffered: v.messages.count}
+}
+
+// History returns the stored non-expired messages of the given topic, oldest first.
+func (v *ValidReplayer) History(topic string) []*Message {
+	if v.messages.count == 0 {
+		return nil
+	}
+
+	now := v.Now()
+
+	var msgs []*Message
+	v.messages.each(v.messages.head)(func(_ int, m messageWithTopicsAndExpiry) bool {
+		if m.exp.After(now) && !m.message.Expired(now) && slices.Contains(m.topics, topic) {
+			msgs = append(msgs, m.message)
+		}
+		return true
+	})
+
+	return msgs
+}
+
+// Purge removes the given topic from the stored messages, so they aren't replayed to its
+// subscribers anymore. Messages which remain without topics are removed once they expire.
+func (v *ValidReplayer) Purge(topic string) int {
+	if v.messages.count == 0 {
+		return 0
+	}
+
+	n := 0
+	v.messages.each(v.messages.head)(func(j int, _ messageWithTopicsAndExpiry) bool {
+		if v.messages.buf[j].removeTopic(topic) {
+			n++
+		}
+		return true
+	})
+
+	return n
+}
+
 // Replay replays all the valid messages to the listener.
+// Each stored message is encoded at most once, the first time it is replayed
+// to an EncodedMessageWriter.
 func (v *ValidReplayer) Replay(subscription Subscription) error {
 	i := findIDInQueue(&v.messages, subscription.LastEventID, v.currentID != nil)
 	if i < 0 {
+		observeReplay(v.Observer, subscription, false, 0)
 		return nil
 	}
 
 	now := v.Now()
 
 	var err error
-	v.messages.each(i)(func(_ int, m messageWithTopicsAndExpiry) bool {
-		if m.exp.After(now) && topicsIntersect(subscription.Topics, m.topics) {
-			if err = subscription.Client.Send(m.message); err != nil {
+	count := 0
+	v.messages.each(i)(func(j int, m messageWithTopicsAndExpiry) bool {
+		if m.exp.After(now) && !m.message.Expired(now) && subscription.accepts(m.message, m.topics) {
+			if err = v.messages.buf[j].sendTo(subscription.Client); err != nil {
 				return false
 			}
+			count++
 		}
 		return true
 	})
+	observeReplay(v.Observer, subscription, true, count)
 	if err != nil {
 		return err
 	}
@@ -215,6 +322,13 @@ func (v *ValidReplayer) Replay(subscription Subscription) error {
 	return subscription.Client.Flush()
 }
 
+// observeReplay notifies the observer, if any, of a replay for a subscription with a last event ID.
+func observeReplay(o Observer, sub Subscription, hit bool, count int) { //nolint:gocritic // intended
+	if o != nil && sub.LastEventID.IsSet() {
+		o.Replayed(hit, count)
+	}
+}
+
 // topicsIntersect returns true if the given topic slices have at least one topic in common.
 func topicsIntersect(a, b []string) bool {
 	for _, at := range a {
@@ -342,6 +456,10 @@ func findIDInQueue[M interface{ ID() EventID }](q *queue[M], id EventID, autoID
 				return -1
 			}
 			pos = int(delta) //nolint:gosec // delta < q.count, which is an int
+			if pos == q.count-1 {
+				// It's the latest message, so there's nothing to replay.
+				return -1
+			}
 		}
 
 		i := pos + q.head + 1
@@ -375,6 +493,21 @@ func findIDInQueue[M interface{ ID() EventID }](q *queue[M], id EventID, autoID
 
 func (m messageWithTopics) ID() EventID { return m.message.ID }
 
+// removeTopic removes the topic from the stored message, reporting whether the message had it.
+// The topics slice is shared with the publisher, so it is never modified in place.
+func (m *messageWithTopics) removeTopic(topic string) bool {
+	if !slices.Contains(m.topics, topic) {
+		return false
+	}
+
+	m.topics = slices.DeleteFunc(slices.Clone(m.topics), func(t string) bool { return t == topic })
+	if len(m.topics) == 0 {
+		m.encoded = nil
+	}
+
+	return true
+}
+
 type messageWithTopicsAndExpiry struct {
 	exp time.Time
 	messageWithTopics
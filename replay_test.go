@@ -3,6 +3,7 @@ package sse_test
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -168,12 +169,86 @@ func TestFiniteReplayProvider(t *testing.T) {
 	_, err = idp.Put(msg(t, "should error", "should not have ID"), []string{sse.DefaultTopic})
 	tests.Expect(t, err != nil, "messages with IDs cannot be put in an autoID replay provider")
 
+	for _, data := range []string{"a", "b", "c"} {
+		put(t, idp, msg(t, data, ""))
+	}
+	tests.Equal(t, len(replay(t, idp, sse.ID("2"))), 0, "nothing should be replayed after the latest message")
+
+	full, err := sse.NewFiniteReplayer(3, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayProvider")
+
+	for _, data := range []string{"a", "b", "c"} {
+		put(t, full, msg(t, data, ""))
+	}
+	tests.Equal(t, len(replay(t, full, sse.ID("2"))), 0, "nothing should be replayed after the latest message of a full buffer")
+
 	tr, err := sse.NewFiniteReplayer(10, false)
 	tests.Equal(t, err, nil, "should create new FiniteReplayProvider")
 
 	testReplayError(t, tr, nil)
 }
 
+func TestReplayer_Filter(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(4, false)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+	val, err := sse.NewValidReplayer(time.Minute, false)
+	tests.Equal(t, err, nil, "should create new ValidReplayer")
+
+	for _, r := range []sse.Replayer{fin, val} {
+		put(t, r, msg(t, "", "1"))
+		put(t, r, msg(t, "public", "2"))
+		put(t, r, msg(t, "private", "3"))
+		put(t, r, msg(t, "public", "4"))
+
+		var replayed []string
+		err := r.Replay(sse.Subscription{
+			Client: mockClient(func(m *sse.Message) error {
+				if m != nil {
+					replayed = append(replayed, m.ID.String())
+				}
+				return nil
+			}),
+			LastEventID: sse.ID("1"),
+			Topics:      []string{sse.DefaultTopic},
+			Filter: func(m *sse.Message) bool {
+				return !strings.Contains(m.String(), "private")
+			},
+		})
+		tests.Equal(t, err, nil, "unexpected replay error")
+		tests.DeepEqual(t, replayed, []string{"2", "4"}, "filtered messages should not be replayed (%T)", r)
+	}
+}
+
+func TestReplayer_Expiry(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(4, false)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+	val, err := sse.NewValidReplayer(time.Minute, false)
+	tests.Equal(t, err, nil, "should create new ValidReplayer")
+
+	expiring := func(id string, expiry time.Time) *sse.Message {
+		m := msg(t, "", id)
+		m.Expiry = expiry
+		return m
+	}
+
+	for _, r := range []sse.Replayer{fin, val} {
+		put(t, r, msg(t, "", "1"))
+		put(t, r, expiring("2", time.Now().Add(-time.Second)))
+		put(t, r, expiring("3", time.Now().Add(time.Hour)))
+		put(t, r, msg(t, "", "4"))
+
+		var replayed []string
+		for _, m := range replay(t, r, sse.ID("1")) {
+			replayed = append(replayed, m.ID.String())
+		}
+		tests.DeepEqual(t, replayed, []string{"3", "4"}, "expired messages should not be replayed (%T)", r)
+	}
+}
+
 func TestFiniteReplayProvider_allocations(t *testing.T) {
 	p, err := sse.NewFiniteReplayer(3, false)
 	tests.Equal(t, err, nil, "should create new FiniteReplayProvider")
@@ -20,8 +20,13 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
+	"time"
 )
 
 // The Subscription struct is used to subscribe to a given provider.
@@ -36,6 +41,29 @@ type Subscription struct {
 	// The topics to receive message from. Must be a non-empty list.
 	// Topics are orthogonal to event types. They are used to filter what the server sends to each client.
 	Topics []string
+	// An optional predicate which decides whether a message published to the subscription's
+	// topics is sent to its client. Providers must not send – live or replayed – the messages
+	// for which it returns false; replayers don't have to check it, as the provider enforces
+	// it for the messages they replay too. Use it to keep some messages private to certain
+	// clients without creating a topic for each client.
+	//
+	// The function is called by providers synchronously, before each message is sent,
+	// so it should return as fast as possible. The message must not be modified.
+	Filter func(m *Message) bool
+	// An optional function which returns the current state of the subscription's topics, as messages
+	// sent only to this subscription. Use it for streams where new clients need the current state
+	// instead of a replay of older messages.
+	//
+	// Providers must call it after the messages are replayed and before any live message is sent,
+	// so that no message published concurrently is lost or sent before the snapshot. The Filter
+	// also applies to the snapshot's messages. Joe calls it from its event loop, so it should
+	// return as fast as possible.
+	Snapshot func() []*Message
+}
+
+// accepts reports whether the given message must be sent to the subscription.
+func (s Subscription) accepts(m *Message, topics []string) bool { //nolint:gocritic // intended
+	return topicsIntersect(s.Topics, topics) && (s.Filter == nil || s.Filter(m))
 }
 
 // A Provider is a publish-subscribe system that can be used to implement a HTML5 server-sent events
@@ -68,6 +96,40 @@ type Provider interface {
 	Shutdown(ctx context.Context) error
 }
 
+// A Publication is a Message together with the topics it is published to.
+type Publication struct {
+	// The message to publish.
+	Message *Message
+	// The topics the message is published to. When publishing through a Provider
+	// this must be a non-empty list.
+	Topics []string
+}
+
+// A BatchPublisher is a Provider which can publish multiple messages at once,
+// more efficiently than by publishing them one by one.
+//
+// Providers are not required to implement this interface – Server.PublishBatch
+// falls back to calling Publish for each message if the provider doesn't.
+type BatchPublisher interface {
+	// PublishBatch publishes all the given messages to the subscribers of their topics.
+	// The messages must be sent in the given order. If any of the publications has
+	// no topics, ErrNoTopic must be returned and no message published.
+	PublishBatch(batch []Publication) error
+}
+
+// An IdempotentPublisher is a Provider which drops duplicate publishes – for example,
+// publishes retried by producers after a timeout.
+//
+// Providers are not required to implement this interface – Server.PublishOnce
+// returns errors.ErrUnsupported if the provider doesn't.
+type IdempotentPublisher interface {
+	// PublishOnce publishes the message, unless a message with the same idempotency key
+	// was published within the provider's deduplication window. It returns the ID the
+	// message was published with or, for duplicates, the ID of the original message,
+	// so that producers can correlate them.
+	PublishOnce(key string, message *Message, topics []string) (EventID, error)
+}
+
 // ErrProviderClosed is a sentinel error returned by providers when any operation is attempted after the provider is closed.
 // A closed provider might also be a result of an unexpected panic inside the provider.
 var ErrProviderClosed = errors.New("go-sse.server: provider is closed")
@@ -91,6 +153,9 @@ type Server struct {
 	// The provider used to publish and subscribe clients to events.
 	// Defaults to Joe.
 	Provider Provider
+	// ProviderMiddleware wraps the Provider, so that its calls can be intercepted.
+	// The first middleware is the outermost one. See ProviderMiddleware for more info.
+	ProviderMiddleware []ProviderMiddleware
 	// A callback that's called when an SSE session is started.
 	// You can use this to authorize the session, set the topics
 	// the client should be subscribed to and so on. Using the
@@ -109,12 +174,151 @@ type Server struct {
 	// If this is not set, the client will be subscribed to the provider
 	// using the DefaultTopic.
 	OnSession func(w http.ResponseWriter, r *http.Request) (topics []string, allowed bool)
+	// AcceptSession is an alternative to OnSession which doesn't require writing
+	// the response when refusing a session. It returns the topics the client
+	// should be subscribed to or an error if the session is refused.
+	//
+	// If the error is a *RejectionError, the Server writes a problem details
+	// response using the information it provides. Any other error results in
+	// a 403 Forbidden response without details. In both cases the error is logged.
+	//
+	// If no topics are returned, the client is subscribed to the DefaultTopic.
+	// If both OnSession and AcceptSession are set, only AcceptSession is used.
+	AcceptSession func(r *http.Request) (topics []string, err error)
 	// If the Logger function is set and returns a non-nil Logger instance,
 	// the Server will log various information about the request lifecycle.
 	Logger func(r *http.Request) *slog.Logger
+	// FlushPolicy configures how the messages sent to each session are coalesced.
+	// By default each message is flushed to the client as soon as it is sent.
+	// See the FlushPolicy documentation for more info.
+	FlushPolicy FlushPolicy
+	// WriteTimeout is the maximum duration a write to a session's client may take.
+	// Clients which don't receive the sent messages in time are disconnected and
+	// the provider receives a *WriteTimeoutError from the session.
+	// If it is zero, writes don't time out.
+	WriteTimeout time.Duration
+	// MaxSessionDuration is the maximum duration a session may last. When it passes,
+	// the server sends the client a retry hint and ends the stream cleanly, so that
+	// the client reconnects – possibly to another instance, after scaling out.
+	// Reconnecting clients resume the stream using the Last-Event-ID header,
+	// so if the provider replays events no events are lost.
+	//
+	// If it is zero, sessions last until the client disconnects or the server is shut down.
+	MaxSessionDuration time.Duration
+	// MaxSessionJitter is the maximum random duration added to each session's
+	// MaxSessionDuration, so that sessions started at the same time don't all
+	// end at the same time.
+	MaxSessionJitter time.Duration
+	// ReconnectDelay is the retry hint sent to clients whose session is ended by the server.
+	// Clients wait this long before reconnecting. Defaults to one second.
+	ReconnectDelay time.Duration
+	// ShutdownMessage is an optional message sent to every connected session
+	// when the server is shut down, together with the ReconnectDelay retry hint.
+	// Use it to let clients know that they should reconnect, maybe to another instance.
+	// The message is not published through the provider.
+	ShutdownMessage *Message
+	// MaxSessions is the maximum number of concurrent sessions. Requests over the limit are
+	// refused with a 503 Service Unavailable response and a Retry-After header,
+	// before the session is upgraded. If it is zero, there is no limit.
+	MaxSessions int
+	// MaxSessionsPerTopic is the maximum number of concurrent sessions subscribed to a topic.
+	// Requests over the limit are refused with a 503 Service Unavailable response and
+	// a Retry-After header, after the topics are known but before any event is sent.
+	// If it is zero, there is no limit.
+	MaxSessionsPerTopic int
+	// MaxSessionsPerClient is the maximum number of concurrent sessions of a single client,
+	// as identified by ClientKey. Requests over the limit are refused with a 429 Too Many Requests
+	// response and a Retry-After header, before the session is upgraded. If it is zero,
+	// there is no limit.
+	MaxSessionsPerClient int
+	// ClientKey returns the key which identifies the client that made the request –
+	// for example, the user ID. It is used to enforce MaxSessionsPerClient.
+	// Defaults to the IP address from the request's RemoteAddr.
+	ClientKey func(r *http.Request) string
+	// Authorizer is consulted for each topic requested by a session, to allow,
+	// deny or narrow it. If no topics are allowed, the session is rejected with
+	// a 403 Forbidden response. If it is nil, all topics are allowed.
+	Authorizer Authorizer
+	// Identify extracts the identity of the client from the request – for example,
+	// the user ID from an authentication token. The identity is given to the Authorizer.
+	// Returning an error rejects the session – see AcceptSession for how errors are written.
+	Identify func(r *http.Request) (string, error)
+	// AuthorizationInterval configures how often the Authorizer is consulted again
+	// for active sessions. Sessions which aren't allowed anymore to subscribe to
+	// all of their topics are ended, so revoked permissions take effect for
+	// connected clients too. If it is zero, sessions are only authorized once.
+	AuthorizationInterval time.Duration
+	// SessionFilter returns the message filter of a session. See Subscription.Filter
+	// for more information. The identity is the one returned by Identify.
+	// If it returns nil, all messages are sent to the session.
+	SessionFilter func(r *http.Request, identity string) func(m *Message) bool
+	// Snapshot returns the current state of a topic, as messages sent only to a new session
+	// subscribed to that topic. It is called for each of the session's topics, after replay
+	// and before any live message is sent to the session. See Subscription.Snapshot for more info.
+	Snapshot func(topic string) []*Message
+	// SessionMiddleware wraps each session before it is subscribed to the provider,
+	// so that the messages sent to it can be transformed – see MessageWriterMiddleware.
+	// The first middleware is the outermost one: it is the first to receive each message.
+	SessionMiddleware []MessageWriterMiddleware
+	// Observer is notified of started, ended and rejected sessions and of the bytes
+	// written to each session. If the Provider is not set, the default Joe provider
+	// also uses this observer. Other providers and replayers must be configured
+	// with an observer separately.
+	Observer Observer
+	// OnTopicActive and OnTopicInactive are called when a topic gets its first subscriber
+	// and when its last subscriber leaves. They are used only by the default Joe provider,
+	// if the Provider is not set. See the Joe fields with the same names for more info.
+	OnTopicActive   func(topic string)
+	OnTopicInactive func(topic string)
+	// ScheduleStore persists the messages scheduled using PublishAt and PublishAfter, so that
+	// they can be restored after a restart using RestoreScheduled. If it is nil and the Provider
+	// is a Joe whose Replayer implements ScheduleStore, the replayer is used.
+	ScheduleStore ScheduleStore
+	// Acks enables delivery acknowledgements: the messages sent to each client identity are
+	// tracked until the client acknowledges them using the AckHandler, and the unacknowledged
+	// ones are sent again, after replay, when the client reconnects. Clients may receive
+	// duplicates and their Last-Event-ID may move backwards. Sessions without
+	// an identity – see Identify – are not tracked. See AckTracker for more info.
+	//
+	// Messages are tracked before they are given to the SessionMiddleware, so they are
+	// transformed again when they are sent again. Messages which the middleware drops
+	// are never acknowledged, so they stay pending until they are evicted.
+	Acks *AckTracker
+	// Cursors enables durable subscriptions: the ID of the last event delivered to each
+	// client identity is saved in the store and a client which reconnects without a
+	// Last-Event-ID resumes the stream from it. This way, clients which lose their
+	// Last-Event-ID – for example, mobile apps which are restarted – keep their place.
+	// Sessions without an identity – see Identify – are not tracked. Use a CursorStore
+	// backed by persistent storage to keep the cursors across server restarts.
+	//
+	// The cursor is saved when the session's messages are written to the client, so messages
+	// buffered according to the FlushPolicy are not considered delivered until the buffer
+	// is written. Snapshot messages, including the unacknowledged messages sent again – see
+	// Acks –, don't move the cursor.
+	Cursors CursorStore
 
-	provider Provider
-	initDone sync.Once
+	provider  Provider
+	store     ScheduleStore
+	scheduler *timerWheel
+	sessions  map[*serverSession]struct{}
+	clients   map[string]int
+	topics    map[string]int
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	initDone  sync.Once
+	draining  bool
+	lastID    uint64
+}
+
+// serverSession holds the server's state of an active session.
+type serverSession struct {
+	started    time.Time
+	cancel     context.CancelCauseFunc
+	id         string
+	clientKey  string
+	remoteAddr string
+	identity   string
+	topics     []string
 }
 
 // ServeHTTP implements a default HTTP handler for a server.
@@ -142,6 +346,16 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		l.Info("sse: starting new session")
 	}
 
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+
+	ss, err := s.register(r, cancel)
+	if err != nil {
+		s.reject(w, r, l, err)
+		return
+	}
+	defer s.unregister(ss)
+
 	sess, err := Upgrade(w, r)
 	if err != nil {
 		if l != nil {
@@ -152,12 +366,64 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sub, ok := s.getSubscription(sess)
-	if !ok {
+	sess.FlushPolicy = s.FlushPolicy
+	sess.WriteTimeout = s.WriteTimeout
+	sess.Observer = s.Observer
+
+	sub, err := s.getSubscription(sess)
+	if err == errSessionNotAllowed { //nolint:errorlint // it's our error
+		// OnSession is responsible for writing the response.
 		if l != nil {
 			l.Warn("sse: invalid subscription")
 		}
 
+		return
+	} else if err != nil {
+		s.reject(w, r, l, err)
+		return
+	}
+
+	identity, err := s.identify(r)
+	if err != nil {
+		s.reject(w, r, l, err)
+		return
+	}
+
+	requested := sub.Topics
+	if s.Authorizer != nil {
+		if sub.Topics, err = s.authorize(r, identity, requested); err != nil {
+			s.reject(w, r, l, err)
+			return
+		}
+	}
+
+	if s.SessionFilter != nil {
+		sub.Filter = s.SessionFilter(r, identity)
+	}
+
+	if s.Snapshot != nil {
+		sub.Snapshot = s.snapshot(sub.Topics)
+	}
+
+	sub.Client = applyMiddleware(r, sub.Client, s.SessionMiddleware)
+
+	if s.Acks != nil && identity != "" {
+		sub.Client = &ackWriter{next: sub.Client, tracker: s.Acks, identity: identity, topics: sub.Topics}
+		sub.Snapshot = s.Acks.redeliver(identity, sub.Topics, sub.Snapshot)
+	}
+
+	if s.Cursors != nil && identity != "" {
+		sub.LastEventID = s.resumeFrom(sub, identity, l)
+		cw := &cursorWriter{next: sub.Client, store: s.Cursors, logger: l, identity: identity}
+		if sub.Snapshot != nil {
+			sub.Snapshot = cw.snapshot(sub.Snapshot)
+		}
+		sub.Client = cw
+		sess.onFlush = cw.flushed
+	}
+
+	if err = s.activate(ss, identity, sub.Topics); err != nil {
+		s.reject(w, r, l, err)
 		return
 	}
 
@@ -165,7 +431,43 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		l.Info("sse: subscribing session", "topics", sub.Topics, "lastEventID", sub.LastEventID)
 	}
 
-	if err = s.provider.Subscribe(r.Context(), sub); err != nil {
+	if d := s.sessionDuration(); d > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, d, errSessionExpired)
+		defer cancelTimeout()
+	}
+
+	if s.Authorizer != nil && s.AuthorizationInterval > 0 {
+		go s.watchAuthorization(ctx, ss, r, requested, sub.Topics, l)
+	}
+
+	if s.Observer != nil {
+		s.Observer.SessionStarted(r)
+	}
+
+	err = s.provider.Subscribe(ctx, sub)
+	if err == nil {
+		s.requestReconnect(ctx, sess, l)
+	}
+	// Messages still buffered are flushed on a best-effort basis:
+	// if this fails, the client is gone anyway.
+	_ = sess.Close()
+
+	if s.Observer != nil {
+		s.Observer.SessionEnded(r, err)
+	}
+
+	var timeoutErr *WriteTimeoutError
+	if errors.As(err, &timeoutErr) {
+		// The client isn't reading anymore, so there's no point in writing an error response.
+		if l != nil {
+			l.Warn("sse: session write timed out", "timeout", timeoutErr.Timeout)
+		}
+
+		return
+	}
+
+	if err != nil {
 		if l != nil {
 			l.Error("sse: subscribe error", "error", err)
 		}
@@ -186,8 +488,62 @@ func (s *Server) Publish(e *Message, topics ...string) error {
 	return s.provider.Publish(e, getTopics(topics))
 }
 
-// Shutdown closes all the connections and stops the server. Publish operations will fail
-// with the error sent by the underlying provider. NewServer requests will be ignored.
+// PublishBatch sends all the given events to the subscribers of their topics.
+// Publications without topics are published to the DefaultTopic.
+//
+// If the provider implements BatchPublisher, the whole batch is given to it at once.
+// Otherwise, the messages are published one by one and the errors returned
+// by the provider are joined together.
+func (s *Server) PublishBatch(batch ...Publication) error {
+	s.init()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	b := make([]Publication, len(batch))
+	for i, p := range batch {
+		b[i] = Publication{Message: p.Message, Topics: getTopics(p.Topics)}
+	}
+
+	return publishBatch(s.provider, b)
+}
+
+// PublishOnce publishes the event to the given topics, unless an event with the same idempotency key
+// was recently published. The topics are optional - if none are specified, the event is published
+// to the DefaultTopic. It returns the ID of the published event or, for duplicates, the ID
+// of the original event.
+//
+// It returns errors.ErrUnsupported if the provider doesn't implement IdempotentPublisher.
+// See Joe.DedupeWindow for the default provider.
+func (s *Server) PublishOnce(key string, e *Message, topics ...string) (EventID, error) {
+	s.init()
+	return publishOnce(s.provider, key, e, getTopics(topics))
+}
+
+// Stats returns a snapshot of the provider's state, if the provider – or any provider
+// wrapped by the ProviderMiddleware – implements StatsProvider. Otherwise, it returns
+// errors.ErrUnsupported.
+func (s *Server) Stats() (ProviderStats, error) {
+	s.init()
+
+	sp, ok := findProvider[StatsProvider](s.provider)
+	if !ok {
+		return ProviderStats{}, errors.ErrUnsupported
+	}
+
+	return sp.Stats()
+}
+
+// Shutdown gracefully drains all the sessions and stops the server.
+//
+// Once Shutdown is called new sessions are refused with a 503 Service Unavailable
+// response and a Retry-After header. Every connected session is unsubscribed from
+// the provider and receives the ShutdownMessage, if any, and the ReconnectDelay
+// retry hint. After all sessions have flushed their messages and ended – or after
+// the context is done – the provider is shut down. Publish operations will then fail
+// with the error sent by the underlying provider. Scheduled messages which were not
+// yet published are not published anymore, but they remain in the ScheduleStore.
 //
 // Call this method when shutting down the HTTP server using http.Server's RegisterOnShutdown
 // method. Not doing this will result in the server never shutting down or connections being
@@ -196,30 +552,278 @@ func (s *Server) Publish(e *Message, topics ...string) error {
 // See the Provider.Shutdown documentation for information on context usage and errors.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.init()
+	s.stopScheduled()
+
+	s.mu.Lock()
+	s.draining = true
+	for ss := range s.sessions {
+		ss.cancel(errServerShutdown)
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		// The provider is still shut down, so that the remaining sessions are forcefully closed.
+	}
+
 	return s.provider.Shutdown(ctx)
 }
 
+var (
+	errSessionExpired = errors.New("go-sse.server: session expired")
+	errServerShutdown = errors.New("go-sse.server: server is shutting down")
+
+	errSessionDisconnected = errors.New("go-sse.server: session disconnected")
+
+	errSessionLimit       = errors.New("go-sse.server: too many sessions")
+	errTopicSessionLimit  = errors.New("go-sse.server: too many sessions for topic")
+	errClientSessionLimit = errors.New("go-sse.server: too many sessions for client")
+)
+
+// register adds a new session to the server, if the server is not shutting down
+// and the global and per-client session limits are not reached.
+func (s *Server) register(r *http.Request, cancel context.CancelCauseFunc) (*serverSession, error) {
+	var key string
+	if s.MaxSessionsPerClient > 0 {
+		key = s.clientKey(r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return nil, errServerShutdown
+	}
+	if s.MaxSessions > 0 && len(s.sessions) >= s.MaxSessions {
+		return nil, errSessionLimit
+	}
+	if s.MaxSessionsPerClient > 0 && s.clients[key] >= s.MaxSessionsPerClient {
+		return nil, errClientSessionLimit
+	}
+
+	s.lastID++
+	ss := &serverSession{
+		started:    time.Now(),
+		cancel:     cancel,
+		id:         strconv.FormatUint(s.lastID, 10),
+		clientKey:  key,
+		remoteAddr: r.RemoteAddr,
+	}
+	s.sessions[ss] = struct{}{}
+	if s.MaxSessionsPerClient > 0 {
+		s.clients[key]++
+	}
+	s.wg.Add(1)
+
+	return ss, nil
+}
+
+// activate records the identity of the session and the topics it is subscribed to,
+// if the per-topic session limit is not reached for any of them.
+func (s *Server) activate(ss *serverSession, identity string, topics []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.MaxSessionsPerTopic > 0 {
+		for i, t := range topics {
+			if !slices.Contains(topics[:i], t) && s.topics[t] >= s.MaxSessionsPerTopic {
+				return errTopicSessionLimit
+			}
+		}
+	}
+
+	ss.identity = identity
+	for i, t := range topics {
+		if !slices.Contains(topics[:i], t) {
+			ss.topics = append(ss.topics, t)
+			if s.MaxSessionsPerTopic > 0 {
+				s.topics[t]++
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) unregister(ss *serverSession) {
+	s.mu.Lock()
+	delete(s.sessions, ss)
+	if s.MaxSessionsPerClient > 0 {
+		decrement(s.clients, ss.clientKey)
+	}
+	if s.MaxSessionsPerTopic > 0 {
+		for _, t := range ss.topics {
+			decrement(s.topics, t)
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Done()
+}
+
+func decrement(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+	} else {
+		counts[key]--
+	}
+}
+
+func (s *Server) clientKey(r *http.Request) string {
+	if s.ClientKey != nil {
+		return s.ClientKey(r)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (s *Server) sessionDuration() time.Duration {
+	d := s.MaxSessionDuration
+	if d > 0 && s.MaxSessionJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(s.MaxSessionJitter)))
+	}
+
+	return d
+}
+
+func (s *Server) reconnectDelay() time.Duration {
+	if s.ReconnectDelay > 0 {
+		return s.ReconnectDelay
+	}
+
+	return time.Second
+}
+
+// requestReconnect ends the session cleanly and asks the client to reconnect,
+// if the session was ended by the server.
+func (s *Server) requestReconnect(ctx context.Context, sess *Session, l *slog.Logger) {
+	var final *Message
+
+	switch context.Cause(ctx) { //nolint:errorlint // it's our error
+	case errSessionExpired:
+		if l != nil {
+			l.Info("sse: session expired")
+		}
+	case errServerShutdown:
+		if l != nil {
+			l.Info("sse: server is shutting down, ending session")
+		}
+
+		final = s.ShutdownMessage
+	case errSessionDisconnected:
+		if l != nil {
+			l.Info("sse: session disconnected")
+		}
+
+		return
+	default:
+		// Sessions which aren't authorized anymore don't reconnect.
+		return
+	}
+
+	if err := s.endSession(sess, final); err != nil && l != nil {
+		l.Warn("sse: failed to request reconnect", "error", err)
+	}
+}
+
+// retryAfter formats the given delay as the value of a Retry-After header.
+func retryAfter(d time.Duration) string {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+
+	return strconv.FormatInt(secs, 10)
+}
+
+// endSession sends the final message, if any, and tells the client to reconnect
+// after the configured delay. It must be called only after the session is not
+// subscribed anymore.
+func (s *Server) endSession(sess *Session, final *Message) error {
+	m := &Message{}
+	if final != nil {
+		m = final.Clone()
+	}
+	m.Retry = s.reconnectDelay()
+
+	if err := sess.Send(m); err != nil {
+		return err
+	}
+
+	return sess.Flush()
+}
+
 func (s *Server) init() {
 	s.initDone.Do(func() {
 		s.provider = s.Provider
 		if s.provider == nil {
-			s.provider = &Joe{}
+			s.provider = &Joe{
+				Observer:        s.Observer,
+				OnTopicActive:   s.OnTopicActive,
+				OnTopicInactive: s.OnTopicInactive,
+			}
+		}
+		s.provider = ChainProvider(s.provider, s.ProviderMiddleware...)
+		s.store = s.ScheduleStore
+		if j, ok := findProvider[*Joe](s.provider); ok && s.store == nil {
+			s.store, _ = j.Replayer.(ScheduleStore)
 		}
+		s.scheduler = newTimerWheel(s.publishScheduled)
+		s.sessions = map[*serverSession]struct{}{}
+		s.clients = map[string]int{}
+		s.topics = map[string]int{}
 	})
 }
 
-func (s *Server) getSubscription(sess *Session) (Subscription, bool) {
+// snapshot returns a function which gathers the snapshots of all the given topics.
+func (s *Server) snapshot(topics []string) func() []*Message {
+	return func() []*Message {
+		var msgs []*Message
+		for i, t := range topics {
+			if !slices.Contains(topics[:i], t) {
+				msgs = append(msgs, s.Snapshot(t)...)
+			}
+		}
+
+		return msgs
+	}
+}
+
+var errSessionNotAllowed = errors.New("go-sse.server: session not allowed")
+
+func (s *Server) getSubscription(sess *Session) (Subscription, error) {
 	sub := Subscription{Client: sess, LastEventID: sess.LastEventID, Topics: defaultTopicSlice}
-	if s.OnSession != nil {
+	if s.AcceptSession != nil {
+		topics, err := s.AcceptSession(sess.Req)
+		if err != nil {
+			return sub, err
+		}
+		if len(topics) > 0 {
+			sub.Topics = topics
+		}
+	} else if s.OnSession != nil {
 		topics, ok := s.OnSession(sess.Res, sess.Req)
-		if ok && len(topics) > 0 {
+		if !ok {
+			return sub, errSessionNotAllowed
+		}
+		if len(topics) > 0 {
 			sub.Topics = topics
 		}
-
-		return sub, ok
 	}
 
-	return sub, true
+	return sub, nil
 }
 
 var defaultTopicSlice = []string{DefaultTopic}
@@ -0,0 +1,286 @@
+package sse
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxPendingAcks is the default maximum number of unacknowledged messages
+// an AckTracker keeps for each client identity.
+const DefaultMaxPendingAcks = 1000
+
+// An AckTracker keeps track of the messages sent to each client identity which
+// weren't yet acknowledged by the client. Set it as the Server's Acks to enable
+// at-least-once delivery: clients acknowledge the IDs of the events they processed
+// by sending them to the Server's AckHandler, and the unacknowledged messages are
+// sent again when the client reconnects, even if its Last-Event-ID moved ahead.
+//
+// Only messages with an ID are tracked, as the others can't be acknowledged.
+// As with any at-least-once delivery, clients must handle duplicates: the unacknowledged
+// messages newer than the client's Last-Event-ID are both replayed and sent again.
+// The messages sent again are older than the ones replayed, so they also move the
+// client's Last-Event-ID backwards, and on its next reconnect the messages after the
+// oldest of them are replayed again.
+//
+// Each message is sent again only to sessions subscribed to all the topics of the session
+// it was first sent to, so that clients don't receive messages of topics they aren't
+// subscribed to or authorized for anymore.
+//
+// The zero value is ready to use and it is safe for concurrent use.
+type AckTracker struct {
+	pending map[string]*pendingAcks
+	// MaxPending is the maximum number of unacknowledged messages kept for each identity.
+	// When it is exceeded, the oldest messages are dropped. Defaults to DefaultMaxPendingAcks.
+	MaxPending int
+	mu         sync.Mutex
+}
+
+// pendingAcks holds the unacknowledged messages of an identity, in the order they were sent.
+type pendingAcks struct {
+	ids      map[string]struct{}
+	messages []pendingAck
+}
+
+// pendingAck is an unacknowledged message together with the topics of the session it was sent to.
+type pendingAck struct {
+	message *Message
+	topics  []string
+}
+
+// Ack acknowledges the messages with the given IDs sent to the given identity.
+// Unknown IDs are ignored.
+func (t *AckTracker) Ack(identity string, ids ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.pending[identity]
+	if p == nil {
+		return
+	}
+
+	acked := false
+	for _, id := range ids {
+		if _, ok := p.ids[id]; ok {
+			delete(p.ids, id)
+			acked = true
+		}
+	}
+
+	if !acked {
+		return
+	}
+
+	p.messages = slices.DeleteFunc(p.messages, func(a pendingAck) bool {
+		_, ok := p.ids[a.message.ID.String()]
+		return !ok
+	})
+
+	if len(p.messages) == 0 {
+		delete(t.pending, identity)
+	}
+}
+
+// Pending returns the unacknowledged non-expired messages sent to the given identity,
+// oldest first. The returned messages must not be modified.
+func (t *AckTracker) Pending(identity string) []*Message {
+	return t.pendingFor(identity, nil)
+}
+
+// pendingFor returns the unacknowledged non-expired messages which can be sent to
+// a session of the identity subscribed to the given topics. All the messages are
+// returned if the topics are nil.
+func (t *AckTracker) pendingFor(identity string, topics []string) []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.pending[identity]
+	if p == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	msgs := make([]*Message, 0, len(p.messages))
+	for _, a := range p.messages {
+		if a.message.Expired(now) || (topics != nil && !topicsContain(topics, a.topics)) {
+			continue
+		}
+
+		msgs = append(msgs, a.message)
+	}
+
+	return msgs
+}
+
+// topicsContain reports whether all the topics of b are in a.
+func topicsContain(a, b []string) bool {
+	for _, topic := range b {
+		if !slices.Contains(a, topic) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// track records a message sent to a session of the given identity subscribed to the given topics.
+func (t *AckTracker) track(identity string, m *Message, topics []string) {
+	if !m.ID.IsSet() || m.ID.String() == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending == nil {
+		t.pending = map[string]*pendingAcks{}
+	}
+
+	p := t.pending[identity]
+	if p == nil {
+		p = &pendingAcks{ids: map[string]struct{}{}}
+		t.pending[identity] = p
+	}
+
+	id := m.ID.String()
+	// Messages are sent again on reconnect and an identity can have multiple sessions.
+	if _, ok := p.ids[id]; ok {
+		return
+	}
+
+	p.ids[id] = struct{}{}
+	p.messages = append(p.messages, pendingAck{message: m, topics: topics})
+
+	if maxPending := t.maxPending(); len(p.messages) > maxPending {
+		dropped := len(p.messages) - maxPending
+		for _, a := range p.messages[:dropped] {
+			delete(p.ids, a.message.ID.String())
+		}
+		p.messages = slices.Delete(p.messages, 0, dropped)
+	}
+}
+
+func (t *AckTracker) maxPending() int {
+	if t.MaxPending <= 0 {
+		return DefaultMaxPendingAcks
+	}
+	return t.MaxPending
+}
+
+// redeliver returns a subscription snapshot function which sends the identity's
+// unacknowledged messages for the session's topics before the snapshot returned
+// by the given function, if any. The pending messages are retrieved immediately,
+// so the messages replayed to the new session, which are tracked during replay,
+// aren't included. Pending messages newer than the session's Last-Event-ID are
+// replayed and then sent again – see AckTracker.
+func (t *AckTracker) redeliver(identity string, topics []string, snapshot func() []*Message) func() []*Message {
+	pending := t.pendingFor(identity, topics)
+
+	return func() []*Message {
+		msgs := pending
+		if snapshot != nil {
+			msgs = append(msgs, snapshot()...)
+		}
+
+		return msgs
+	}
+}
+
+// ackWriter records the messages sent to a session in the AckTracker.
+type ackWriter struct {
+	next     MessageWriter
+	tracker  *AckTracker
+	identity string
+	topics   []string
+}
+
+func (a *ackWriter) Send(m *Message) error {
+	if err := a.next.Send(m); err != nil {
+		return err
+	}
+
+	a.tracker.track(a.identity, m, a.topics)
+	return nil
+}
+
+func (a *ackWriter) SendEncoded(m *EncodedMessage) error {
+	var err error
+	if ew, ok := a.next.(EncodedMessageWriter); ok {
+		err = ew.SendEncoded(m)
+	} else {
+		err = a.next.Send(m.Message())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.tracker.track(a.identity, m.Message(), a.topics)
+	return nil
+}
+
+func (a *ackWriter) Flush() error {
+	return a.next.Flush()
+}
+
+// maxAckBodySize is the maximum size of an acknowledgement request's body.
+const maxAckBodySize = 1 << 20
+
+var errNoIdentity = errors.New("go-sse.server: acknowledgements require a client identity")
+
+// AckHandler returns a handler which receives acknowledgements from clients. Clients send
+// a POST request with the acknowledged event IDs in the body, one per line – the Client
+// does this automatically if its AckURL is set. The client is identified using the server's
+// Identify function, so the request must carry the same credentials as the client's sessions.
+//
+// The handler responds with 204 No Content on success. It responds with 501 Not Implemented
+// if the server has no AckTracker and with 403 Forbidden if the client has no identity.
+func (s *Server) AckHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			(&RejectionError{Status: http.StatusMethodNotAllowed}).writeTo(w)
+			return
+		}
+
+		if s.Acks == nil {
+			(&RejectionError{Status: http.StatusNotImplemented, Detail: "acknowledgements are not enabled"}).writeTo(w)
+			return
+		}
+
+		identity, err := s.identify(r)
+		if err != nil {
+			var rej *RejectionError
+			if !errors.As(err, &rej) {
+				rej = s.rejectionFor(err)
+			}
+
+			rej.writeTo(w)
+			return
+		}
+		if identity == "" {
+			(&RejectionError{Detail: errorDetail(errNoIdentity)}).writeTo(w)
+			return
+		}
+
+		var ids []string
+		sc := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxAckBodySize))
+		for sc.Scan() {
+			if id := strings.TrimSpace(sc.Text()); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			(&RejectionError{Status: http.StatusBadRequest, Detail: "invalid acknowledgement body"}).writeTo(w)
+			return
+		}
+
+		s.Acks.Ack(identity, ids...)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,164 @@
+package sse_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func pendingIDs(t *sse.AckTracker, identity string) []string {
+	var ids []string
+	for _, m := range t.Pending(identity) {
+		ids = append(ids, m.ID.String())
+	}
+	return ids
+}
+
+func TestServer_Acks(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(4, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	acks := &sse.AckTracker{}
+	s := &sse.Server{
+		Provider: &sse.Joe{Replayer: fin},
+		Identify: func(*http.Request) (string, error) { return "alice", nil },
+		Acks:     acks,
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", s)
+	mux.Handle("/ack", s.AckHandler())
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	for _, data := range []string{"a", "b", "c"} {
+		_ = s.Publish(msg(t, data, ""))
+	}
+
+	// receive connects with the given Last-Event-ID and returns the IDs of the first two events.
+	// After receiving them, the connection is closed once the given function returns.
+	receive := func(c *sse.Client, lastEventID string, wait func()) []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events", http.NoBody)
+		r.Header.Set("Last-Event-ID", lastEventID)
+
+		var ids []string
+		conn := c.NewConnection(r)
+		conn.SubscribeMessages(func(e sse.Event) {
+			ids = append(ids, e.LastEventID)
+			if len(ids) == 2 {
+				go func() {
+					wait()
+					cancel()
+				}()
+			}
+		})
+		_ = conn.Connect()
+
+		return ids
+	}
+
+	tests.DeepEqual(t, receive(&sse.Client{}, "0", func() {}), []string{"1", "2"}, "invalid replayed events")
+	tests.DeepEqual(t, pendingIDs(acks, "alice"), []string{"1", "2"}, "sent events should be pending")
+
+	// The Last-Event-ID moved ahead, but the events weren't acknowledged.
+	ids := receive(&sse.Client{AckURL: ts.URL + "/ack"}, "2", func() {
+		deadline := time.Now().Add(time.Second)
+		for len(acks.Pending("alice")) > 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	tests.DeepEqual(t, ids, []string{"1", "2"}, "unacknowledged events should be redelivered")
+	tests.Equal(t, len(acks.Pending("alice")), 0, "events should be acknowledged by the client")
+}
+
+func TestServer_Acks_topics(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(10, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	acks := &sse.AckTracker{}
+	s := &sse.Server{
+		Provider: &sse.Joe{Replayer: fin},
+		OnSession: func(_ http.ResponseWriter, r *http.Request) ([]string, bool) {
+			return r.URL.Query()["topic"], true
+		},
+		Identify: func(*http.Request) (string, error) { return "alice", nil },
+		Acks:     acks,
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	_ = s.Publish(msg(t, "c", ""), "c")
+	_ = s.Publish(msg(t, "a", ""), "a")
+	_ = s.Publish(msg(t, "b", ""), "b")
+
+	// receive connects with the given Last-Event-ID and, after the first event, publishes
+	// a marker to the given topic. It returns the IDs of the events received until its marker.
+	receive := func(query, lastEventID, markerTopic string) []string {
+		marker := "marker " + query
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?"+query, http.NoBody)
+		r.Header.Set("Last-Event-ID", lastEventID)
+
+		var ids []string
+		conn := (&sse.Client{}).NewConnection(r)
+		conn.SubscribeMessages(func(e sse.Event) {
+			if e.Data == marker {
+				cancel()
+				return
+			}
+
+			ids = append(ids, e.LastEventID)
+			if len(ids) == 1 {
+				go func() { _ = s.Publish(msg(t, marker, ""), markerTopic) }()
+			}
+		})
+		_ = conn.Connect()
+
+		return ids
+	}
+
+	tests.DeepEqual(t, receive("topic=a", "0", "a"), []string{"1"}, "invalid replayed events")
+	// The pending event of topic a must not be sent to a session of topic b.
+	tests.DeepEqual(t, receive("topic=b", "0", "b"), []string{"2"}, "events of other topics should not be redelivered")
+	// The pending events, including the markers, are sent again in the order they were sent.
+	tests.DeepEqual(t, receive("topic=a&topic=b", "4", "a"), []string{"1", "3", "2", "4"}, "pending events should be redelivered")
+}
+
+func TestServer_AckHandler(t *testing.T) {
+	t.Parallel()
+
+	post := func(s *sse.Server, body string) int {
+		rec := httptest.NewRecorder()
+		s.AckHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	tests.Equal(t, post(&sse.Server{}, "1"), http.StatusNotImplemented, "acknowledgements should require a tracker")
+	tests.Equal(t, post(&sse.Server{Acks: &sse.AckTracker{}}, "1"), http.StatusForbidden, "acknowledgements should require an identity")
+
+	rec := httptest.NewRecorder()
+	(&sse.Server{Acks: &sse.AckTracker{}}).AckHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	tests.Equal(t, rec.Code, http.StatusMethodNotAllowed, "only POST should be allowed")
+
+	s := &sse.Server{Acks: &sse.AckTracker{}, Identify: func(*http.Request) (string, error) { return "alice", nil }}
+	tests.Equal(t, post(s, "1\n2\n"), http.StatusNoContent, "invalid acknowledgement status")
+}
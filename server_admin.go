@@ -0,0 +1,217 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// AdminHandler returns a handler which exposes the server's live state as JSON
+// and allows operators to act on it. It serves the following routes:
+//
+//	GET  /                         the sessions, subscribers per topic and provider stats
+//	GET  /topics/{topic}/history   the messages of the topic stored by the replayer
+//	POST /sessions/{id}/disconnect ends the session with the given ID
+//	POST /topics/{topic}/purge     removes the topic's history from the replayer
+//
+// Mount it using http.StripPrefix, for example:
+//
+//	mux.Handle("/debug/sse/", http.StripPrefix("/debug/sse", s.AdminHandler()))
+//
+// Provider stats and topic history are available only if the provider – or any
+// provider wrapped by the ProviderMiddleware – implements StatsProvider and
+// HistoryProvider respectively, as Joe does. Otherwise, the stats are omitted
+// and the history routes respond with 501 Not Implemented.
+//
+// The handler does no authentication or authorization of its own: it exposes
+// client identities and addresses and it can disconnect clients, so protect it
+// using your own middleware before exposing it.
+func (s *Server) AdminHandler() http.Handler {
+	s.init()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.adminState)
+	mux.HandleFunc("GET /topics/{topic}/history", s.adminHistory)
+	mux.HandleFunc("POST /sessions/{id}/disconnect", s.adminDisconnect)
+	mux.HandleFunc("POST /topics/{topic}/purge", s.adminPurge)
+
+	return mux
+}
+
+type adminSession struct {
+	Started    time.Time `json:"started"`
+	ID         string    `json:"id"`
+	Identity   string    `json:"identity,omitempty"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Age        string    `json:"age"`
+	Topics     []string  `json:"topics"`
+}
+
+type adminProviderStats struct {
+	TopicSubscribers map[string]int      `json:"topicSubscribers"`
+	Replayer         *adminReplayerStats `json:"replayer,omitempty"`
+	Subscribers      int                 `json:"subscribers"`
+	Published        uint64              `json:"published"`
+}
+
+type adminReplayerStats struct {
+	Buffered int `json:"buffered"`
+	Capacity int `json:"capacity,omitempty"`
+}
+
+type adminState struct {
+	Topics   map[string]int      `json:"topics"`
+	Provider *adminProviderStats `json:"provider,omitempty"`
+	Sessions []adminSession      `json:"sessions"`
+}
+
+type adminMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type,omitempty"`
+	Raw  string `json:"raw"`
+	Size int    `json:"size"`
+}
+
+type adminHistory struct {
+	Topic    string         `json:"topic"`
+	Messages []adminMessage `json:"messages"`
+	Count    int            `json:"count"`
+	Size     int            `json:"size"`
+}
+
+type adminPurge struct {
+	Topic  string `json:"topic"`
+	Purged int    `json:"purged"`
+}
+
+func (s *Server) adminState(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	state := adminState{Topics: map[string]int{}, Sessions: []adminSession{}}
+
+	s.mu.Lock()
+	for ss := range s.sessions {
+		state.Sessions = append(state.Sessions, adminSession{
+			Started:    ss.started,
+			ID:         ss.id,
+			Identity:   ss.identity,
+			RemoteAddr: ss.remoteAddr,
+			Age:        now.Sub(ss.started).Round(time.Millisecond).String(),
+			Topics:     slices.Clone(ss.topics),
+		})
+		for _, t := range ss.topics {
+			state.Topics[t]++
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(state.Sessions, func(a, b adminSession) int { return a.Started.Compare(b.Started) })
+
+	if sp, ok := findProvider[StatsProvider](s.provider); ok {
+		stats, err := sp.Stats()
+		if err != nil {
+			writeAdminError(w, err)
+			return
+		}
+
+		state.Provider = &adminProviderStats{
+			TopicSubscribers: stats.TopicSubscribers,
+			Subscribers:      stats.Subscribers,
+			Published:        stats.Published,
+		}
+		if stats.Replayer != nil {
+			state.Provider.Replayer = &adminReplayerStats{Buffered: stats.Replayer.Buffered, Capacity: stats.Replayer.Capacity}
+		}
+	}
+
+	writeAdminJSON(w, state)
+}
+
+func (s *Server) adminHistory(w http.ResponseWriter, r *http.Request) {
+	hp, ok := findProvider[HistoryProvider](s.provider)
+	if !ok {
+		writeAdminError(w, errors.ErrUnsupported)
+		return
+	}
+
+	topic := r.PathValue("topic")
+
+	msgs, err := hp.History(topic)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	h := adminHistory{Topic: topic, Messages: make([]adminMessage, 0, len(msgs)), Count: len(msgs)}
+	for _, m := range msgs {
+		raw := m.String()
+		h.Messages = append(h.Messages, adminMessage{ID: m.ID.String(), Type: m.Type.String(), Raw: raw, Size: len(raw)})
+		h.Size += len(raw)
+	}
+
+	writeAdminJSON(w, h)
+}
+
+func (s *Server) adminDisconnect(w http.ResponseWriter, r *http.Request) {
+	if !s.disconnect(r.PathValue("id")) {
+		(&RejectionError{Status: http.StatusNotFound, Detail: "session not found"}).writeTo(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) adminPurge(w http.ResponseWriter, r *http.Request) {
+	hp, ok := findProvider[HistoryProvider](s.provider)
+	if !ok {
+		writeAdminError(w, errors.ErrUnsupported)
+		return
+	}
+
+	topic := r.PathValue("topic")
+
+	n, err := hp.Purge(topic)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	writeAdminJSON(w, adminPurge{Topic: topic, Purged: n})
+}
+
+// disconnect ends the session with the given ID, reporting whether it exists.
+func (s *Server) disconnect(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ss := range s.sessions {
+		if ss.id == id {
+			ss.cancel(errSessionDisconnected)
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	rej := &RejectionError{Status: http.StatusInternalServerError, Detail: err.Error()}
+
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		rej.Status = http.StatusNotImplemented
+		rej.Detail = "the provider doesn't support this operation"
+	case errors.Is(err, ErrProviderClosed):
+		rej.Status = http.StatusServiceUnavailable
+		rej.Detail = "the provider is closed"
+	}
+
+	rej.writeTo(w)
+}
@@ -0,0 +1,109 @@
+package sse_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+type startObserver struct {
+	sse.NopObserver
+	started chan struct{}
+}
+
+func (o startObserver) SessionStarted(*http.Request) { close(o.started) }
+
+func adminRequest(tb testing.TB, h http.Handler, method, target string, v any) int {
+	tb.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, http.NoBody))
+	if v != nil {
+		tests.Equal(tb, json.Unmarshal(rec.Body.Bytes(), v), nil, "invalid JSON response")
+	}
+
+	return rec.Code
+}
+
+func TestServer_AdminHandler(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(3, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	o := startObserver{started: make(chan struct{})}
+	s := &sse.Server{
+		Provider: &sse.Joe{Replayer: fin},
+		Observer: o,
+		Identify: func(*http.Request) (string, error) { return "alice", nil },
+		OnSession: func(http.ResponseWriter, *http.Request) ([]string, bool) {
+			return []string{"news", "sports"}, true
+		},
+	}
+	h := s.AdminHandler()
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("", "/", http.NoBody))
+	}()
+	<-o.started
+
+	_ = s.Publish(msg(t, "hello", ""), "news")
+	_ = s.Publish(msg(t, "score", ""), "news", "sports")
+
+	var state struct {
+		Topics   map[string]int `json:"topics"`
+		Provider struct {
+			Replayer  struct{ Buffered, Capacity int } `json:"replayer"`
+			Published uint64                           `json:"published"`
+		} `json:"provider"`
+		Sessions []struct {
+			ID       string   `json:"id"`
+			Identity string   `json:"identity"`
+			Topics   []string `json:"topics"`
+		} `json:"sessions"`
+	}
+	tests.Equal(t, adminRequest(t, h, http.MethodGet, "/", &state), http.StatusOK, "invalid state status")
+	tests.Equal(t, len(state.Sessions), 1, "invalid session count")
+	tests.Equal(t, state.Sessions[0].Identity, "alice", "invalid session identity")
+	tests.DeepEqual(t, state.Sessions[0].Topics, []string{"news", "sports"}, "invalid session topics")
+	tests.DeepEqual(t, state.Topics, map[string]int{"news": 1, "sports": 1}, "invalid topic counts")
+	tests.Equal(t, state.Provider.Published, uint64(2), "invalid published count")
+	tests.Equal(t, state.Provider.Replayer.Buffered, 2, "invalid buffered count")
+	tests.Equal(t, state.Provider.Replayer.Capacity, 3, "invalid replayer capacity")
+
+	var history struct {
+		Messages []struct{ ID, Raw string } `json:"messages"`
+		Count    int                        `json:"count"`
+	}
+	tests.Equal(t, adminRequest(t, h, http.MethodGet, "/topics/news/history", &history), http.StatusOK, "invalid history status")
+	tests.Equal(t, history.Count, 2, "invalid history count")
+	tests.Equal(t, history.Messages[1].Raw, "id: 1\ndata: score\n\n", "invalid history message")
+
+	var purge struct{ Purged int }
+	tests.Equal(t, adminRequest(t, h, http.MethodPost, "/topics/news/purge", &purge), http.StatusOK, "invalid purge status")
+	tests.Equal(t, purge.Purged, 2, "invalid purged count")
+	tests.Equal(t, len(replay(t, fin, sse.ID("0"), "news")), 0, "purged messages should not be replayed")
+	tests.Equal(t, len(replay(t, fin, sse.ID("0"), "sports")), 1, "messages of other topics should still be replayed")
+
+	tests.Equal(t, adminRequest(t, h, http.MethodPost, "/sessions/unknown/disconnect", nil), http.StatusNotFound, "unknown sessions should not be found")
+	tests.Equal(t, adminRequest(t, h, http.MethodPost, "/sessions/"+state.Sessions[0].ID+"/disconnect", nil), http.StatusNoContent, "invalid disconnect status")
+	<-served
+
+	tests.Equal(t, adminRequest(t, h, http.MethodGet, "/", &state), http.StatusOK, "invalid state status")
+	tests.Equal(t, len(state.Sessions), 0, "disconnected session should be removed")
+}
+
+func TestServer_AdminHandler_unsupported(t *testing.T) {
+	t.Parallel()
+
+	h := (&sse.Server{Provider: &mockProvider{}}).AdminHandler()
+
+	tests.Equal(t, adminRequest(t, h, http.MethodGet, "/topics/news/history", nil), http.StatusNotImplemented, "history should not be supported")
+	tests.Equal(t, adminRequest(t, h, http.MethodPost, "/topics/news/purge", nil), http.StatusNotImplemented, "purge should not be supported")
+}
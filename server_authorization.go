@@ -0,0 +1,111 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// An Authorizer decides which topics a session is allowed to subscribe to.
+// It is consulted by the Server for each topic requested by a session,
+// after the topics are returned by OnSession or AcceptSession.
+//
+// Authorizers are required to be thread-safe.
+type Authorizer interface {
+	// AuthorizeTopic returns the topics the session is allowed to subscribe to
+	// instead of the requested topic. Return the topic itself to allow it, other
+	// topics to narrow it – for example "orders" to "orders/<user ID>" – or no
+	// topics to deny it.
+	//
+	// The identity is the one returned by Server.Identify. Returning an error
+	// rejects the session altogether – see Server.AcceptSession for how the errors
+	// are written to the client.
+	AuthorizeTopic(r *http.Request, identity, topic string) ([]string, error)
+}
+
+// AuthorizerFunc is an adapter which allows using ordinary functions as Authorizers.
+type AuthorizerFunc func(r *http.Request, identity, topic string) ([]string, error)
+
+// AuthorizeTopic calls the function.
+func (f AuthorizerFunc) AuthorizeTopic(r *http.Request, identity, topic string) ([]string, error) {
+	return f(r, identity, topic)
+}
+
+var (
+	errNoAuthorizedTopics   = errors.New("go-sse.server: no authorized topics")
+	errAuthorizationRevoked = errors.New("go-sse.server: session authorization revoked")
+)
+
+// identify returns the identity of the client which made the request.
+func (s *Server) identify(r *http.Request) (string, error) {
+	if s.Identify == nil {
+		return "", nil
+	}
+
+	return s.Identify(r)
+}
+
+// authorize returns all the topics the Authorizer allows for the requested topics.
+// It errors if no topic is allowed.
+func (s *Server) authorize(r *http.Request, identity string, requested []string) ([]string, error) {
+	var topics []string
+
+	for _, t := range requested {
+		allowed, err := s.Authorizer.AuthorizeTopic(r, identity, t)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range allowed {
+			if !slices.Contains(topics, a) {
+				topics = append(topics, a)
+			}
+		}
+	}
+
+	if len(topics) == 0 {
+		return nil, errNoAuthorizedTopics
+	}
+
+	return topics, nil
+}
+
+// watchAuthorization periodically checks that the session is still allowed to
+// subscribe to all the granted topics. If it is not, the session is ended.
+func (s *Server) watchAuthorization(ctx context.Context, ss *serverSession, r *http.Request, requested, granted []string, l *slog.Logger) {
+	t := time.NewTicker(s.AuthorizationInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			topics, err := s.authorize(r, ss.identity, requested)
+			if err == nil && !isSubset(granted, topics) {
+				err = errAuthorizationRevoked
+			}
+			if err != nil {
+				if l != nil {
+					l.Warn("sse: session authorization revoked", "error", err)
+				}
+
+				ss.cancel(errAuthorizationRevoked)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func isSubset(a, b []string) bool {
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			return false
+		}
+	}
+
+	return true
+}
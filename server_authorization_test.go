@@ -0,0 +1,123 @@
+package sse_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func TestServer_Authorizer(t *testing.T) {
+	t.Parallel()
+
+	authorizer := sse.AuthorizerFunc(func(_ *http.Request, identity, topic string) ([]string, error) {
+		switch topic {
+		case "orders":
+			return []string{"orders/" + identity}, nil
+		case "news":
+			return []string{topic}, nil
+		case "forbidden":
+			return nil, &sse.RejectionError{Status: http.StatusForbidden, Detail: "nope"}
+		default:
+			return nil, nil
+		}
+	})
+	identify := func(r *http.Request) (string, error) {
+		user := r.Header.Get("User")
+		if user == "" {
+			return "", &sse.RejectionError{Status: http.StatusUnauthorized}
+		}
+		return user, nil
+	}
+
+	serve := func(t *testing.T, user string, topics ...string) (*httptest.ResponseRecorder, *mockProvider) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		req, cancel := request(t, "", "/", http.NoBody)
+		req.Header.Set("User", user)
+		go cancel()
+
+		p := newMockProvider(t, nil)
+		(&sse.Server{
+			Provider:   p,
+			Authorizer: authorizer,
+			Identify:   identify,
+			AcceptSession: func(*http.Request) ([]string, error) {
+				return topics, nil
+			},
+		}).ServeHTTP(rec, req)
+
+		return rec, p
+	}
+
+	t.Run("Narrow", func(t *testing.T) {
+		t.Parallel()
+
+		rec, p := serve(t, "alice", "orders", "news", "admin")
+		tests.Equal(t, rec.Code, http.StatusOK, "invalid response code")
+		tests.DeepEqual(t, p.Sub.Topics, []string{"orders/alice", "news"}, "invalid authorized topics")
+	})
+
+	t.Run("Deny", func(t *testing.T) {
+		t.Parallel()
+
+		rec, p := serve(t, "alice", "admin")
+		tests.Expect(t, !p.Subscribed, "session should not be subscribed")
+		tests.Equal(t, rec.Code, http.StatusForbidden, "invalid response code")
+		tests.Equal(t, rec.Body.String(), `{"type":"about:blank","title":"Forbidden","detail":"no authorized topics","status":403}`+"\n", "invalid response body")
+
+		rec, _ = serve(t, "alice", "news", "forbidden")
+		tests.Equal(t, rec.Code, http.StatusForbidden, "authorizer error should reject the session")
+	})
+
+	t.Run("Identify", func(t *testing.T) {
+		t.Parallel()
+
+		rec, p := serve(t, "", "news")
+		tests.Expect(t, !p.Subscribed, "session should not be subscribed")
+		tests.Equal(t, rec.Code, http.StatusUnauthorized, "invalid response code")
+	})
+}
+
+func TestServer_AuthorizationInterval(t *testing.T) {
+	t.Parallel()
+
+	var revoked atomic.Bool
+
+	j := &sse.Joe{}
+	cleanupJoe(t, j)
+
+	s := &sse.Server{
+		Provider:              j,
+		AuthorizationInterval: time.Millisecond,
+		Authorizer: sse.AuthorizerFunc(func(_ *http.Request, _, topic string) ([]string, error) {
+			if revoked.Load() {
+				return nil, nil
+			}
+			return []string{topic}, nil
+		}),
+	}
+
+	req := httptest.NewRequest("", "/", http.NoBody)
+	ctx, cancel := context.WithTimeout(req.Context(), time.Second)
+	t.Cleanup(cancel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	}()
+
+	time.Sleep(time.Millisecond * 5)
+	revoked.Store(true)
+	<-done
+
+	tests.Expect(t, !errors.Is(ctx.Err(), context.DeadlineExceeded), "session should be ended when authorization is revoked")
+}
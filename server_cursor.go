@@ -0,0 +1,159 @@
+package sse
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// A CursorStore remembers the ID of the last event delivered to each client identity.
+// Set it as the Server's Cursors to enable durable subscriptions: a client which
+// reconnects without a Last-Event-ID – for example, after an app restart – resumes
+// the stream from the last event delivered to its identity.
+//
+// Implementations must be thread-safe.
+type CursorStore interface {
+	// LoadCursor returns the ID of the last event delivered to the given identity.
+	// It returns an unset EventID if there is no stored cursor for the identity.
+	LoadCursor(identity string) (EventID, error)
+	// SaveCursor stores the ID of the last event delivered to the given identity.
+	SaveCursor(identity string, id EventID) error
+}
+
+// MemoryCursorStore is a CursorStore which keeps the cursors in memory.
+// The zero value is ready to use.
+type MemoryCursorStore struct {
+	cursors map[string]EventID
+	mu      sync.RWMutex
+}
+
+// LoadCursor implements CursorStore. It never returns an error.
+func (m *MemoryCursorStore) LoadCursor(identity string) (EventID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cursors[identity], nil
+}
+
+// SaveCursor implements CursorStore. It never returns an error.
+func (m *MemoryCursorStore) SaveCursor(identity string, id EventID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cursors == nil {
+		m.cursors = map[string]EventID{}
+	}
+	m.cursors[identity] = id
+
+	return nil
+}
+
+// cursorWriter saves the ID of the last event delivered to a session in the CursorStore.
+// Events are delivered when the session writes them to the client, which it reports
+// by calling flushed – possibly later than Flush, if the session buffers messages.
+//
+// The snapshot's messages, which include the unacknowledged messages sent again,
+// don't move the cursor, as they aren't in the order of the stream.
+type cursorWriter struct {
+	next     MessageWriter
+	store    CursorStore
+	logger   *slog.Logger
+	identity string
+	skip     map[*Message]struct{}
+	last     EventID
+	mu       sync.Mutex
+	unsaved  bool
+}
+
+func (c *cursorWriter) Send(m *Message) error {
+	if err := c.next.Send(m); err != nil {
+		return err
+	}
+
+	c.record(m)
+	return nil
+}
+
+func (c *cursorWriter) SendEncoded(m *EncodedMessage) error {
+	var err error
+	if ew, ok := c.next.(EncodedMessageWriter); ok {
+		err = ew.SendEncoded(m)
+	} else {
+		err = c.next.Send(m.Message())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.record(m.Message())
+	return nil
+}
+
+func (c *cursorWriter) Flush() error {
+	return c.next.Flush()
+}
+
+// snapshot returns a subscription snapshot function which returns the messages of the
+// given one, remembering them so they don't move the cursor.
+func (c *cursorWriter) snapshot(next func() []*Message) func() []*Message {
+	return func() []*Message {
+		msgs := next()
+		if len(msgs) > 0 {
+			c.skip = make(map[*Message]struct{}, len(msgs))
+			for _, m := range msgs {
+				c.skip[m] = struct{}{}
+			}
+		}
+
+		return msgs
+	}
+}
+
+func (c *cursorWriter) record(m *Message) {
+	if _, ok := c.skip[m]; ok {
+		delete(c.skip, m)
+		return
+	}
+
+	if m.ID.IsSet() && m.ID.String() != "" {
+		c.mu.Lock()
+		c.last = m.ID
+		c.unsaved = true
+		c.mu.Unlock()
+	}
+}
+
+// flushed saves the last event sent to the session, as it was written to the client.
+func (c *cursorWriter) flushed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.unsaved {
+		return
+	}
+
+	c.unsaved = false
+	// The session goes on if this fails: the client resumes from an older event on reconnect.
+	if err := c.store.SaveCursor(c.identity, c.last); err != nil && c.logger != nil {
+		c.logger.Warn("sse: failed to save cursor", "error", err, "lastEventID", c.last)
+	}
+}
+
+// resumeFrom returns the stored cursor of the identity, if the client didn't send a Last-Event-ID.
+func (s *Server) resumeFrom(sub Subscription, identity string, l *slog.Logger) EventID {
+	if sub.LastEventID.IsSet() {
+		return sub.LastEventID
+	}
+
+	id, err := s.Cursors.LoadCursor(identity)
+	if err != nil {
+		// The session isn't refused: it just won't replay the missed events.
+		if l != nil {
+			l.Warn("sse: failed to load cursor", "error", err)
+		}
+
+		return sub.LastEventID
+	}
+
+	return id
+}
@@ -0,0 +1,222 @@
+package sse_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func TestServer_Cursors(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(10, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	cursors := &sse.MemoryCursorStore{}
+	s := &sse.Server{
+		Provider: &sse.Joe{Replayer: fin},
+		Identify: func(r *http.Request) (string, error) { return r.Header.Get("X-Client"), nil },
+		Cursors:  cursors,
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	// publish waits for the previous sessions to end, so that they don't receive the messages.
+	publish := func(data ...string) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			stats, err := s.Stats()
+			tests.Equal(t, err, nil, "unexpected stats error")
+			if stats.Subscribers == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		for _, d := range data {
+			_ = s.Publish(msg(t, d, ""))
+		}
+	}
+
+	// receive connects as the given client and returns the IDs of the first count events.
+	receive := func(client, lastEventID string, count int) []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, http.NoBody)
+		r.Header.Set("X-Client", client)
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		var ids []string
+		conn := (&sse.Client{}).NewConnection(r)
+		conn.SubscribeMessages(func(e sse.Event) {
+			ids = append(ids, e.LastEventID)
+			if len(ids) == count {
+				cancel()
+			}
+		})
+		_ = conn.Connect()
+
+		return ids
+	}
+
+	// waitCursor waits until the cursor of the client is saved by the server.
+	waitCursor := func(client, expected string) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			id, err := cursors.LoadCursor(client)
+			tests.Equal(t, err, nil, "unexpected load error")
+			if id.String() == expected || time.Now().After(deadline) {
+				tests.Equal(t, id.String(), expected, "invalid saved cursor")
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	publish("a", "b", "c")
+
+	tests.DeepEqual(t, receive("phone", "0", 2), []string{"1", "2"}, "invalid replayed events")
+	waitCursor("phone", "2")
+
+	publish("d", "e")
+
+	tests.DeepEqual(t, receive("phone", "", 2), []string{"3", "4"}, "client without Last-Event-ID should resume from its cursor")
+	waitCursor("phone", "4")
+
+	tests.DeepEqual(t, receive("phone", "2", 2), []string{"3", "4"}, "Last-Event-ID should take precedence over the cursor")
+
+	id, _ := cursors.LoadCursor("tablet")
+	tests.Expect(t, !id.IsSet(), "unknown identity should have no cursor")
+}
+
+// waitSubscribers waits until the server's provider has the given number of subscribers.
+func waitSubscribers(t *testing.T, s *sse.Server, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, err := s.Stats()
+		tests.Equal(t, err, nil, "unexpected stats error")
+		if stats.Subscribers == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", count, stats.Subscribers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_Cursors_buffered(t *testing.T) {
+	t.Parallel()
+
+	cursors := &sse.MemoryCursorStore{}
+	s := &sse.Server{
+		Identify: func(*http.Request) (string, error) { return "phone", nil },
+		Cursors:  cursors,
+		// Only messages which fill the buffer are written before the session ends.
+		FlushPolicy: sse.FlushPolicy{MaxLatency: time.Hour, MaxBytes: 64},
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, http.NoBody)
+	go func() { _ = (&sse.Client{}).NewConnection(r).Connect() }()
+	waitSubscribers(t, s, 1)
+
+	_ = s.Publish(msg(t, "small", "1"))
+	// Stats is answered by Joe after the message was sent to the session.
+	waitSubscribers(t, s, 1)
+
+	id, _ := cursors.LoadCursor("phone")
+	tests.Expect(t, !id.IsSet(), "cursor should not be saved while the message is buffered")
+
+	_ = s.Publish(msg(t, strings.Repeat("large", 20), "2"))
+	waitSubscribers(t, s, 1)
+
+	// The buffer is written while the large message is sent, so the session reports
+	// it before the large message is recorded as sent.
+	id, _ = cursors.LoadCursor("phone")
+	tests.Equal(t, id.String(), "1", "cursor should be saved after the buffer is written")
+
+	// The session is closed when the client leaves, which writes everything that was sent.
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for id.String() != "2" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		id, _ = cursors.LoadCursor("phone")
+	}
+	tests.Equal(t, id.String(), "2", "cursor should be saved when the session is closed")
+}
+
+func TestServer_Cursors_acks(t *testing.T) {
+	t.Parallel()
+
+	fin, err := sse.NewFiniteReplayer(10, true)
+	tests.Equal(t, err, nil, "should create new FiniteReplayer")
+
+	acks := &sse.AckTracker{}
+	cursors := &sse.MemoryCursorStore{}
+	s := &sse.Server{
+		Provider: &sse.Joe{Replayer: fin},
+		Identify: func(*http.Request) (string, error) { return "phone", nil },
+		Acks:     acks,
+		Cursors:  cursors,
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	for _, data := range []string{"a", "b", "c"} {
+		_ = s.Publish(msg(t, data, ""))
+	}
+
+	// receive connects with the given Last-Event-ID and returns the IDs of the first count events,
+	// after the session ended.
+	receive := func(lastEventID string, count int) []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, http.NoBody)
+		r.Header.Set("Last-Event-ID", lastEventID)
+
+		var ids []string
+		conn := (&sse.Client{}).NewConnection(r)
+		conn.SubscribeMessages(func(e sse.Event) {
+			ids = append(ids, e.LastEventID)
+			if len(ids) == count {
+				cancel()
+			}
+		})
+		_ = conn.Connect()
+		waitSubscribers(t, s, 0)
+
+		return ids
+	}
+
+	tests.DeepEqual(t, receive("0", 2), []string{"1", "2"}, "invalid replayed events")
+	acks.Ack("phone", "2")
+
+	// The unacknowledged event is sent again, but the cursor stays at the latest event.
+	tests.DeepEqual(t, receive("2", 1), []string{"1"}, "unacknowledged events should be redelivered")
+	id, _ := cursors.LoadCursor("phone")
+	tests.Equal(t, id.String(), "2", "redelivered events should not move the cursor back")
+}
@@ -0,0 +1,124 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// A RejectionError describes why a session was refused. Return it from
+// Server.AcceptSession to reject a session with a custom response.
+//
+// The Server writes it as an RFC 9457 problem details response, with
+// the application/problem+json content type. The Err field is only logged,
+// it is never sent to the client.
+type RejectionError struct {
+	// Additional response headers – for example WWW-Authenticate or Retry-After.
+	Header http.Header
+	// The underlying cause of the rejection. Optional.
+	Err error
+	// A URI reference that identifies the problem type. Defaults to "about:blank".
+	Type string
+	// A short, human-readable summary of the problem type.
+	// Defaults to the status code's text.
+	Title string
+	// A human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// The HTTP status code of the response. Defaults to 403 Forbidden.
+	Status int
+}
+
+func (e *RejectionError) Error() string {
+	msg := fmt.Sprintf("go-sse.server: session rejected with status %d", e.status())
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RejectionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RejectionError) status() int {
+	if e.Status == 0 {
+		return http.StatusForbidden
+	}
+	return e.Status
+}
+
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status"`
+}
+
+// writeTo writes the problem details response.
+func (e *RejectionError) writeTo(w http.ResponseWriter) {
+	p := problemDetails{Type: e.Type, Title: e.Title, Detail: e.Detail, Status: e.status()}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	h := w.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// reject writes the response for a session which was refused. Errors which
+// are not a *RejectionError are written as a 403 Forbidden response without details.
+func (s *Server) reject(w http.ResponseWriter, r *http.Request, l *slog.Logger, reason error) {
+	var rej *RejectionError
+	if !errors.As(reason, &rej) {
+		rej = s.rejectionFor(reason)
+	}
+
+	if l != nil {
+		l.Warn("sse: session rejected", "status", rej.status(), "error", reason)
+	}
+	if s.Observer != nil {
+		s.Observer.SessionRejected(r, rej.status())
+	}
+
+	rej.writeTo(w)
+}
+
+func (s *Server) rejectionFor(reason error) *RejectionError {
+	rej := &RejectionError{Err: reason}
+
+	switch reason { //nolint:errorlint // they're our errors
+	case errServerShutdown, errSessionLimit, errTopicSessionLimit, errClientSessionLimit:
+		rej.Status = http.StatusServiceUnavailable
+		if reason == errClientSessionLimit { //nolint:errorlint // it's our error
+			rej.Status = http.StatusTooManyRequests
+		}
+
+		rej.Detail = errorDetail(reason)
+		rej.Header = http.Header{"Retry-After": []string{retryAfter(s.reconnectDelay())}}
+	case errNoAuthorizedTopics:
+		rej.Detail = errorDetail(reason)
+	}
+
+	return rej
+}
+
+// errorDetail returns the message of the error without the package prefix.
+func errorDetail(err error) string {
+	return strings.TrimPrefix(err.Error(), "go-sse.server: ")
+}
@@ -0,0 +1,88 @@
+package sse_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func TestServer_AcceptSession(t *testing.T) {
+	t.Parallel()
+
+	errInvalidToken := errors.New("invalid token")
+
+	type testCase struct {
+		err             error
+		name            string
+		expectedBody    string
+		expectedHeaders http.Header
+		expectedLog     string
+		expectedCode    int
+	}
+
+	testCases := []testCase{
+		{
+			name: "RejectionError",
+			err: &sse.RejectionError{
+				Status: http.StatusUnauthorized,
+				Type:   "https://example.com/problems/auth",
+				Detail: "the token has expired",
+				Header: http.Header{"Www-Authenticate": []string{"Bearer"}},
+				Err:    errInvalidToken,
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"type":"https://example.com/problems/auth","title":"Unauthorized","detail":"the token has expired","status":401}` + "\n",
+			expectedHeaders: http.Header{
+				"Content-Type":           []string{"application/problem+json"},
+				"Www-Authenticate":       []string{"Bearer"},
+				"X-Content-Type-Options": []string{"nosniff"},
+			},
+			expectedLog: "level=WARN msg=\"sse: session rejected\" status=401 error=\"go-sse.server: session rejected with status 401: the token has expired: invalid token\"\n",
+		},
+		{
+			name:         "Error",
+			err:          errInvalidToken,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"type":"about:blank","title":"Forbidden","status":403}` + "\n",
+			expectedHeaders: http.Header{
+				"Content-Type":           []string{"application/problem+json"},
+				"X-Content-Type-Options": []string{"nosniff"},
+			},
+			expectedLog: "level=WARN msg=\"sse: session rejected\" status=403 error=\"invalid token\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("", "/", http.NoBody)
+			p := newMockProvider(t, nil)
+			sb := &strings.Builder{}
+
+			(&sse.Server{
+				Provider: p,
+				Logger:   mockLogFunc(sb),
+				OnSession: func(http.ResponseWriter, *http.Request) ([]string, bool) {
+					t.Error("OnSession should not be called when AcceptSession is set")
+					return nil, true
+				},
+				AcceptSession: func(*http.Request) ([]string, error) {
+					return nil, tc.err
+				},
+			}).ServeHTTP(rec, req)
+
+			tests.Expect(t, !p.Subscribed, "rejected session should not be subscribed")
+			tests.Equal(t, rec.Code, tc.expectedCode, "invalid response code")
+			tests.Equal(t, rec.Body.String(), tc.expectedBody, "invalid response body")
+			tests.DeepEqual(t, rec.Header(), tc.expectedHeaders, "invalid response headers")
+			tests.Equal(t, sb.String(), "level=INFO msg=\"sse: starting new session\"\n"+tc.expectedLog, "invalid log output")
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package sse
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrScheduleCanceled is returned by ScheduledMessage.Err if the message was canceled before it was published.
+var ErrScheduleCanceled = errors.New("go-sse.server: scheduled message canceled")
+
+// ScheduledPublication is a message scheduled to be published at a future time.
+type ScheduledPublication struct {
+	// The time the message must be published at.
+	At time.Time
+	// The message to publish.
+	Message *Message
+	// The ID of the scheduled message. See ScheduledMessage.ID.
+	ID string
+	// The topics to publish the message to.
+	Topics []string
+}
+
+// A ScheduleStore persists the messages scheduled using Server.PublishAt and Server.PublishAfter,
+// so they can be restored after a restart using Server.RestoreScheduled. Replayers which persist
+// their messages can implement it, too.
+//
+// Implementations must be thread-safe.
+type ScheduleStore interface {
+	// StoreScheduled persists a newly scheduled message.
+	StoreScheduled(p ScheduledPublication) error
+	// RemoveScheduled removes a message which was published or canceled.
+	RemoveScheduled(id string) error
+	// LoadScheduled returns all the persisted messages.
+	LoadScheduled() ([]ScheduledPublication, error)
+}
+
+// ScheduledMessage is the handle of a message scheduled using Server.PublishAt or Server.PublishAfter.
+type ScheduledMessage struct {
+	at      time.Time
+	server  *Server
+	message *Message
+	done    chan struct{}
+	err     error
+	id      string
+	topics  []string
+	// The timer wheel's bookkeeping.
+	seq    uint64
+	rounds int64
+	slot   int
+}
+
+// ID returns the unique identifier of the scheduled message.
+func (m *ScheduledMessage) ID() string { return m.id }
+
+// At returns the time the message is scheduled to be published at.
+func (m *ScheduledMessage) At() time.Time { return m.at }
+
+// Done returns a channel which is closed after the message is published or canceled.
+func (m *ScheduledMessage) Done() <-chan struct{} { return m.done }
+
+// Err returns nil if Done is not yet closed. After Done is closed, it returns
+// the error returned by the provider when publishing, ErrScheduleCanceled if
+// the message was canceled or ErrProviderClosed if the server was shut down
+// before the message was published.
+func (m *ScheduledMessage) Err() error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
+
+// Cancel cancels the message, if it wasn't yet published. It reports whether the message was canceled.
+func (m *ScheduledMessage) Cancel() bool {
+	if !m.server.scheduler.remove(m) {
+		return false
+	}
+
+	m.server.unstore(m)
+	m.err = ErrScheduleCanceled
+	close(m.done)
+
+	return true
+}
+
+func (m *ScheduledMessage) publication() ScheduledPublication {
+	return ScheduledPublication{At: m.at, Message: m.message, ID: m.id, Topics: m.topics}
+}
+
+// PublishAt publishes the message to the given topics at the given time. The topics are optional –
+// if none are specified, the message is published to the DefaultTopic. Messages scheduled at a past
+// time are published immediately.
+//
+// Messages are scheduled with a resolution of 10 milliseconds and messages scheduled at the same
+// time are published in the order they were scheduled. Use the returned handle to cancel the message
+// or to wait until it is published.
+//
+// If the server has a ScheduleStore, the message is persisted before it is scheduled. Messages which
+// are not yet published when the server is shut down stay in the store, so they can be restored.
+func (s *Server) PublishAt(at time.Time, m *Message, topics ...string) (*ScheduledMessage, error) {
+	s.init()
+
+	sm := &ScheduledMessage{
+		at:      at,
+		server:  s,
+		message: m,
+		done:    make(chan struct{}),
+		id:      newRandomID(),
+		topics:  getTopics(topics),
+	}
+
+	if s.store != nil {
+		if err := s.store.StoreScheduled(sm.publication()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !s.scheduler.add(sm) {
+		s.unstore(sm)
+		return nil, ErrProviderClosed
+	}
+
+	return sm, nil
+}
+
+// PublishAfter publishes the message to the given topics after the given duration.
+// See PublishAt for more information.
+func (s *Server) PublishAfter(d time.Duration, m *Message, topics ...string) (*ScheduledMessage, error) {
+	return s.PublishAt(time.Now().Add(d), m, topics...)
+}
+
+// RestoreScheduled schedules again all the messages persisted in the server's ScheduleStore.
+// Call it once, when the server is started. Messages whose time has passed are published immediately.
+func (s *Server) RestoreScheduled() ([]*ScheduledMessage, error) {
+	s.init()
+
+	if s.store == nil {
+		return nil, nil
+	}
+
+	pubs, err := s.store.LoadScheduled()
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]*ScheduledMessage, 0, len(pubs))
+	for _, p := range pubs {
+		sm := &ScheduledMessage{
+			at:      p.At,
+			server:  s,
+			message: p.Message,
+			done:    make(chan struct{}),
+			id:      p.ID,
+			topics:  getTopics(p.Topics),
+		}
+
+		if !s.scheduler.add(sm) {
+			return restored, ErrProviderClosed
+		}
+
+		restored = append(restored, sm)
+	}
+
+	return restored, nil
+}
+
+// publishScheduled publishes the messages which are due.
+func (s *Server) publishScheduled(due []*ScheduledMessage) {
+	for _, sm := range due {
+		sm.err = s.provider.Publish(sm.message, sm.topics)
+		s.unstore(sm)
+		close(sm.done)
+	}
+}
+
+// stopScheduled stops the scheduler. The pending messages stay in the store.
+func (s *Server) stopScheduled() {
+	for _, sm := range s.scheduler.stop() {
+		sm.err = ErrProviderClosed
+		close(sm.done)
+	}
+}
+
+func (s *Server) unstore(sm *ScheduledMessage) {
+	if s.store != nil {
+		// If this fails, the message may be published again after a restart.
+		_ = s.store.RemoveScheduled(sm.id)
+	}
+}
+
+func newRandomID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
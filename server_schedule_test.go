@@ -0,0 +1,132 @@
+package sse_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+type syncRecordingProvider struct {
+	recordingProvider
+	mu sync.Mutex
+}
+
+func (s *syncRecordingProvider) Publish(msg *sse.Message, topics []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.recordingProvider.Publish(msg, topics)
+}
+
+func TestServer_PublishAt(t *testing.T) {
+	t.Parallel()
+
+	p := &syncRecordingProvider{}
+	s := &sse.Server{Provider: p}
+
+	now := time.Now()
+	second, err := s.PublishAt(now.Add(time.Millisecond*40), msg(t, "second", ""))
+	tests.Equal(t, err, nil, "unexpected schedule error")
+	first, err := s.PublishAfter(time.Millisecond*20, msg(t, "first", ""), "topic")
+	tests.Equal(t, err, nil, "unexpected schedule error")
+	canceled, err := s.PublishAfter(time.Millisecond*30, msg(t, "canceled", ""))
+	tests.Equal(t, err, nil, "unexpected schedule error")
+
+	tests.Expect(t, canceled.Cancel(), "message should be canceled")
+	tests.Expect(t, !canceled.Cancel(), "message should be canceled only once")
+	tests.ErrorIs(t, canceled.Err(), sse.ErrScheduleCanceled, "invalid canceled message error")
+
+	<-first.Done()
+	<-second.Done()
+	tests.Equal(t, first.Err(), nil, "unexpected publish error")
+	tests.Equal(t, second.Err(), nil, "unexpected publish error")
+	tests.Expect(t, !first.Cancel(), "published messages should not be canceled")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tests.Equal(t, len(p.published), 2, "invalid published message count")
+	tests.Equal(t, p.published[0].Message.String(), "data: first\n\n", "messages should be published in order")
+	tests.DeepEqual(t, p.published[0].Topics, []string{"topic"}, "invalid topics")
+	tests.DeepEqual(t, p.published[1].Topics, []string{sse.DefaultTopic}, "invalid default topics")
+}
+
+type mockScheduleStore struct {
+	scheduled map[string]sse.ScheduledPublication
+	mu        sync.Mutex
+}
+
+func (m *mockScheduleStore) StoreScheduled(p sse.ScheduledPublication) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.scheduled[p.ID] = p
+	return nil
+}
+
+func (m *mockScheduleStore) RemoveScheduled(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.scheduled, id)
+	return nil
+}
+
+func (m *mockScheduleStore) LoadScheduled() ([]sse.ScheduledPublication, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pubs := make([]sse.ScheduledPublication, 0, len(m.scheduled))
+	for _, p := range m.scheduled {
+		pubs = append(pubs, p)
+	}
+	return pubs, nil
+}
+
+func (m *mockScheduleStore) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.scheduled)
+}
+
+func TestServer_RestoreScheduled(t *testing.T) {
+	t.Parallel()
+
+	store := &mockScheduleStore{scheduled: map[string]sse.ScheduledPublication{}}
+
+	s := &sse.Server{Provider: &syncRecordingProvider{}, ScheduleStore: store}
+	sm, err := s.PublishAfter(time.Hour, msg(t, "reminder", ""))
+	tests.Equal(t, err, nil, "unexpected schedule error")
+	tests.Equal(t, store.len(), 1, "message should be persisted")
+
+	tests.Equal(t, s.Shutdown(context.Background()), nil, "unexpected shutdown error")
+	<-sm.Done()
+	tests.ErrorIs(t, sm.Err(), sse.ErrProviderClosed, "pending messages should be stopped on shutdown")
+	tests.Equal(t, store.len(), 1, "pending messages should stay persisted")
+
+	_, err = s.PublishAfter(time.Hour, msg(t, "late", ""))
+	tests.ErrorIs(t, err, sse.ErrProviderClosed, "messages should not be scheduled after shutdown")
+	tests.Equal(t, store.len(), 1, "refused messages should not be persisted")
+
+	store.scheduled[sm.ID()] = sse.ScheduledPublication{At: time.Now().Add(-time.Minute), Message: msg(t, "reminder", ""), ID: sm.ID()}
+
+	p := &syncRecordingProvider{}
+	s = &sse.Server{Provider: p, ScheduleStore: store}
+	restored, err := s.RestoreScheduled()
+	tests.Equal(t, err, nil, "unexpected restore error")
+	tests.Equal(t, len(restored), 1, "invalid restored message count")
+	tests.Equal(t, restored[0].ID(), sm.ID(), "restored message should keep its ID")
+
+	<-restored[0].Done()
+	tests.Equal(t, restored[0].Err(), nil, "unexpected publish error")
+	tests.Equal(t, store.len(), 0, "published messages should be removed from the store")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tests.Equal(t, len(p.published), 1, "restored message should be published")
+}
@@ -111,6 +111,104 @@ func TestServer_ShutdownPublish(t *testing.T) {
 	tests.Expect(t, p.Stopped, "Stop wasn't called")
 }
 
+type recordingProvider struct {
+	mockProvider
+	published []sse.Publication
+}
+
+func (r *recordingProvider) Publish(msg *sse.Message, topics []string) error {
+	r.published = append(r.published, sse.Publication{Message: msg, Topics: topics})
+	return nil
+}
+
+func TestServer_PublishBatch(t *testing.T) {
+	t.Parallel()
+
+	p := &recordingProvider{}
+	s := &sse.Server{Provider: p}
+
+	a, b := &sse.Message{ID: sse.ID("a")}, &sse.Message{ID: sse.ID("b")}
+	tests.Equal(t, s.PublishBatch(sse.Publication{Message: a}, sse.Publication{Message: b, Topics: []string{"topic"}}), nil, "unexpected publish error")
+	tests.DeepEqual(t, p.published, []sse.Publication{
+		{Message: a, Topics: []string{sse.DefaultTopic}},
+		{Message: b, Topics: []string{"topic"}},
+	}, "messages should be published one by one")
+}
+
+func TestServer_Stats(t *testing.T) {
+	t.Parallel()
+
+	s := &sse.Server{Provider: &mockProvider{}}
+	_, err := s.Stats()
+	tests.ErrorIs(t, err, errors.ErrUnsupported, "providers without stats should be reported")
+
+	s = &sse.Server{ProviderMiddleware: []sse.ProviderMiddleware{sse.LogProvider(slog.New(slog.NewTextHandler(io.Discard, nil)))}}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	_ = s.Publish(&sse.Message{})
+
+	stats, err := s.Stats()
+	tests.Equal(t, err, nil, "stats should be found through provider middleware")
+	tests.Equal(t, stats.Published, uint64(1), "invalid published count")
+}
+
+func TestServer_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	p := newMockProvider(t, nil)
+	s := &sse.Server{
+		Provider: p,
+		OnSession: func(http.ResponseWriter, *http.Request) ([]string, bool) {
+			return []string{"a", "b", "a"}, true
+		},
+		Snapshot: func(topic string) []*sse.Message {
+			return []*sse.Message{msg(t, "state of "+topic, "")}
+		},
+	}
+
+	req, cancel := request(t, "", "/", http.NoBody)
+	go cancel()
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	tests.Expect(t, p.Sub.Snapshot != nil, "subscription should have a snapshot")
+
+	var got []string
+	for _, m := range p.Sub.Snapshot() {
+		got = append(got, m.String())
+	}
+	tests.DeepEqual(t, got, []string{"data: state of a\n\n", "data: state of b\n\n"}, "invalid snapshot")
+}
+
+func TestServer_PublishOnce(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&sse.Server{Provider: &mockProvider{}}).PublishOnce("key", &sse.Message{})
+	tests.ErrorIs(t, err, errors.ErrUnsupported, "providers without deduplication should be reported")
+
+	errInvalid := errors.New("invalid")
+	s := &sse.Server{
+		Provider: &sse.Joe{DedupeWindow: time.Minute},
+		ProviderMiddleware: []sse.ProviderMiddleware{sse.ValidatePublish(func(m *sse.Message, _ []string) error {
+			if !m.ID.IsSet() {
+				return errInvalid
+			}
+			return nil
+		})},
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	_, err = s.PublishOnce("key", &sse.Message{})
+	tests.ErrorIs(t, err, errInvalid, "messages should be validated by middleware")
+
+	id, err := s.PublishOnce("key", &sse.Message{ID: sse.ID("first")})
+	tests.Equal(t, err, nil, "unexpected publish error")
+	tests.Equal(t, id, sse.ID("first"), "invalid published ID")
+
+	id, err = s.PublishOnce("key", &sse.Message{ID: sse.ID("retry")})
+	tests.Equal(t, err, nil, "unexpected publish error")
+	tests.Equal(t, id, sse.ID("first"), "duplicates should return the original ID")
+}
+
 func request(tb testing.TB, method, address string, body io.Reader) (*http.Request, context.CancelFunc) { //nolint
 	tb.Helper()
 
@@ -197,6 +295,118 @@ func TestServer_OnSession(t *testing.T) {
 	})
 }
 
+func TestServer_MaxSessionDuration(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("", "/", http.NoBody)
+	req.Header.Set("Last-Event-ID", "5")
+	sb := &strings.Builder{}
+
+	j := &sse.Joe{}
+	cleanupJoe(t, j)
+
+	(&sse.Server{
+		Provider:           j,
+		Logger:             mockLogFunc(sb),
+		MaxSessionDuration: time.Millisecond * 5,
+		MaxSessionJitter:   time.Millisecond,
+		ReconnectDelay:     time.Second * 2,
+	}).ServeHTTP(rec, req)
+
+	tests.Equal(t, rec.Code, http.StatusOK, "invalid response code")
+	tests.Equal(t, rec.Body.String(), "retry: 2000\n\n", "retry hint should be sent")
+	tests.Equal(t, sb.String(), "level=INFO msg=\"sse: starting new session\"\nlevel=INFO msg=\"sse: subscribing session\" topics=[] lastEventID=5\nlevel=INFO msg=\"sse: session expired\"\nlevel=INFO msg=\"sse: session ended\"\n", "invalid log output")
+}
+
+func TestServer_Shutdown_drain(t *testing.T) {
+	t.Parallel()
+
+	final := &sse.Message{Type: sse.Type("close")}
+	final.AppendData("bye")
+
+	subscribed := make(chan struct{})
+	s := &sse.Server{
+		ShutdownMessage: final,
+		ReconnectDelay:  time.Millisecond * 1500,
+		OnSession: func(http.ResponseWriter, *http.Request) ([]string, bool) {
+			close(subscribed)
+			return nil, true
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.ServeHTTP(rec, httptest.NewRequest("", "/", http.NoBody))
+	}()
+
+	<-subscribed
+	tests.Equal(t, s.Shutdown(context.Background()), nil, "unexpected shutdown error")
+	<-served
+
+	tests.Equal(t, rec.Code, http.StatusOK, "invalid response code")
+	tests.Equal(t, rec.Body.String(), "event: close\nretry: 1500\ndata: bye\n\n", "final message should be sent")
+	tests.Equal(t, final.Retry, 0, "final message should not be modified")
+	tests.ErrorIs(t, s.Publish(&sse.Message{}), sse.ErrProviderClosed, "provider should be shut down")
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("", "/", http.NoBody))
+
+	tests.Equal(t, rec.Code, http.StatusServiceUnavailable, "new sessions should be refused")
+	tests.Equal(t, rec.Header().Get("Retry-After"), "2", "invalid Retry-After header")
+}
+
+func TestServer_sessionLimits(t *testing.T) {
+	t.Parallel()
+
+	subscribed := make(chan struct{})
+	s := &sse.Server{
+		MaxSessions:          3,
+		MaxSessionsPerTopic:  1,
+		MaxSessionsPerClient: 2,
+		ReconnectDelay:       time.Second * 3,
+		ClientKey: func(r *http.Request) string {
+			return r.Header.Get("User")
+		},
+		OnSession: func(_ http.ResponseWriter, r *http.Request) ([]string, bool) {
+			subscribed <- struct{}{}
+			return r.URL.Query()["topic"], true
+		},
+	}
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	serve := func(user, topic string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("", "/?topic="+topic, http.NoBody)
+		req.Header.Set("User", user)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	connect := func(user, topic string) {
+		go serve(user, topic)
+		<-subscribed
+	}
+
+	connect("a", "x")
+	connect("a", "y")
+
+	rec := serve("a", "z")
+	tests.Equal(t, rec.Code, http.StatusTooManyRequests, "per-client limit should be enforced")
+	tests.Equal(t, rec.Header().Get("Retry-After"), "3", "invalid Retry-After header")
+
+	go func() { <-subscribed }()
+	rec = serve("b", "x")
+	tests.Equal(t, rec.Code, http.StatusServiceUnavailable, "per-topic limit should be enforced")
+	tests.Equal(t, rec.Header().Get("Retry-After"), "3", "invalid Retry-After header")
+
+	connect("b", "z")
+
+	rec = serve("c", "w")
+	tests.Equal(t, rec.Code, http.StatusServiceUnavailable, "global limit should be enforced")
+}
+
 type flushResponseWriter interface {
 	http.Flusher
 	http.ResponseWriter
@@ -1,8 +1,14 @@
 package sse
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
 // ResponseWriter is a http.ResponseWriter augmented with a Flush method.
@@ -21,6 +27,32 @@ type MessageWriter interface {
 	Flush() error
 }
 
+// An EncodedMessageWriter is a MessageWriter which can also send messages
+// that are already encoded. Providers which send the same message to multiple
+// clients should encode it once and use SendEncoded for the clients which
+// implement this interface, to avoid serializing the message for each client.
+type EncodedMessageWriter interface {
+	MessageWriter
+	// SendEncoded sends the encoded message to the client.
+	// To make sure it is sent, call Flush.
+	SendEncoded(m *EncodedMessage) error
+}
+
+// FlushPolicy configures how a Session coalesces the messages it sends.
+// The zero value sends every message immediately, when Flush is called.
+type FlushPolicy struct {
+	// MaxLatency is the maximum duration a sent message may be buffered before
+	// being flushed to the client. Messages sent in a burst are written to the
+	// client together when this duration passes since the first buffered message.
+	//
+	// If it is zero, messages are not buffered.
+	MaxLatency time.Duration
+	// MaxBytes is the buffer size after which buffered messages are flushed
+	// without waiting for MaxLatency to pass. It has effect only if MaxLatency is set.
+	// If it is zero, the buffer can grow indefinitely during a MaxLatency period.
+	MaxBytes int
+}
+
 // A Session is an HTTP request from an SSE client.
 // Create one using the Upgrade function.
 //
@@ -36,37 +68,245 @@ type Session struct {
 	// Last event ID of the client. It is unset if no ID was provided in the Last-Event-Id
 	// request header.
 	LastEventID EventID
+	// FlushPolicy configures the buffering of sent messages. It must be set before
+	// any message is sent. If buffering is enabled, Close must be called after
+	// the session is done being used.
+	FlushPolicy FlushPolicy
+	// WriteTimeout is the maximum duration each write to the client may take.
+	// If a write takes longer, a *WriteTimeoutError is returned and the session
+	// can't be used anymore. It must be set before any message is sent.
+	// If it is zero, writes don't time out.
+	//
+	// Write deadlines are set using http.ResponseController – if the underlying
+	// http.ResponseWriter doesn't support them, this setting is ignored.
+	// If it is set, Close must be called after the session is done being used,
+	// so that the deadline doesn't linger on the connection.
+	WriteTimeout time.Duration
+	// Observer is notified of the number of bytes written to the client. Optional.
+	Observer Observer
 
+	rc         *http.ResponseController
+	mu         sync.Mutex
+	buf        bytes.Buffer
+	timer      *time.Timer
+	err        error
+	pending    bool
+	closed     bool
 	didUpgrade bool
+
+	// onFlush is called after the sent messages were written and flushed to the client.
+	// For buffered sessions, it is called with the lock held.
+	onFlush func()
 }
 
 // Send sends the given event to the client. It returns any errors that occurred while writing the event.
+//
+// If the session buffers messages, the event is written to the client according to the FlushPolicy
+// and the returned errors may be caused by previously sent events.
 func (s *Session) Send(e *Message) error {
-	if err := s.doUpgrade(); err != nil {
-		return err
+	if s.isBuffered() {
+		return s.sendBuffered(e)
 	}
-	if _, err := e.WriteTo(s.Res); err != nil {
-		return err
+	return s.writeTo(e)
+}
+
+// SendEncoded sends the given encoded event to the client with a single write.
+// It returns any errors that occurred while writing the event.
+//
+// If the session buffers messages, the event is written to the client according to the FlushPolicy
+// and the returned errors may be caused by previously sent events.
+func (s *Session) SendEncoded(e *EncodedMessage) error {
+	if s.isBuffered() {
+		return s.sendBuffered(e)
 	}
-	return nil
+	return s.writeTo(e)
 }
 
 // Flush sends any buffered messages to the client.
+//
+// If the session buffers messages, Flush doesn't write the buffered messages,
+// which will be flushed according to the FlushPolicy. It only returns
+// any error which occurred while doing that.
 func (s *Session) Flush() error {
+	if s.isBuffered() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.err != nil {
+			return s.err
+		}
+		return s.doUpgrade()
+	}
+
 	prevDidUpgrade := s.didUpgrade
 	if err := s.doUpgrade(); err != nil {
 		return err
 	}
 	if prevDidUpgrade == s.didUpgrade {
-		return s.Res.Flush()
+		if err := s.flushResponse(); err != nil {
+			return err
+		}
+	}
+
+	s.flushed()
+	return nil
+}
+
+// Close flushes any buffered messages, stops any pending delayed flush
+// and clears the write deadline. It must be called when the session isn't
+// used anymore, if the session buffers messages or has a write timeout –
+// for example after the Provider's Subscribe method returns.
+// The session must not be used after Close is called.
+//
+// Close does nothing for sessions which neither buffer messages nor have a write timeout.
+func (s *Session) Close() error {
+	if !s.isBuffered() {
+		s.clearWriteDeadline()
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+
+	if s.err == nil && s.buf.Len() > 0 {
+		s.err = s.flushBuffer()
+	} else if s.pending {
+		s.timer.Stop()
+		s.pending = false
+	}
+
+	if s.err == nil {
+		// All the sent messages were written, including the ones
+		// sent after the buffer was last flushed.
+		s.flushed()
+	}
+
+	s.clearWriteDeadline()
+
+	return s.err
+}
+
+func (s *Session) isBuffered() bool {
+	return s.FlushPolicy.MaxLatency > 0
+}
+
+func (s *Session) sendBuffered(e io.WriterTo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return ErrSessionClosed
+	}
+	if err := s.doUpgrade(); err != nil {
+		return err
+	}
+
+	_, _ = e.WriteTo(&s.buf)
+
+	if p := s.FlushPolicy; p.MaxBytes > 0 && s.buf.Len() >= p.MaxBytes {
+		s.err = s.flushBuffer()
+		return s.err
+	}
+
+	if !s.pending {
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.FlushPolicy.MaxLatency, s.flushDelayed)
+		} else {
+			s.timer.Reset(s.FlushPolicy.MaxLatency)
+		}
+		s.pending = true
+	}
+
+	return nil
+}
+
+func (s *Session) flushDelayed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The buffer was already flushed by Send or Close.
+	if !s.pending {
+		return
+	}
+	s.pending = false
+
+	if s.err == nil {
+		s.err = s.flushBuffer()
+	}
+}
+
+// flushBuffer writes the buffered messages to the client. It must be called with the lock held.
+func (s *Session) flushBuffer() error {
+	if s.pending {
+		s.timer.Stop()
+		s.pending = false
+	}
+
+	if err := s.setWriteDeadline(); err != nil {
+		return err
+	}
+
+	n, err := s.buf.WriteTo(s.Res)
+	s.buf.Reset()
+	s.observeWrite(n)
+	if err != nil {
+		return s.wrapWriteErr(err)
+	}
+
+	if err := s.flushResponse(); err != nil {
+		return err
 	}
+
+	s.flushed()
 	return nil
 }
 
+func (s *Session) flushed() {
+	if s.onFlush != nil {
+		s.onFlush()
+	}
+}
+
+func (s *Session) writeTo(e io.WriterTo) error {
+	if err := s.doUpgrade(); err != nil {
+		return err
+	}
+	if err := s.setWriteDeadline(); err != nil {
+		return err
+	}
+	n, err := e.WriteTo(s.Res)
+	s.observeWrite(n)
+	if err != nil {
+		return s.wrapWriteErr(err)
+	}
+	return nil
+}
+
+func (s *Session) observeWrite(n int64) {
+	if s.Observer != nil && n > 0 {
+		s.Observer.BytesWritten(int(n))
+	}
+}
+
+func (s *Session) flushResponse() error {
+	if err := s.setWriteDeadline(); err != nil {
+		return err
+	}
+	return s.wrapWriteErr(s.Res.Flush())
+}
+
 func (s *Session) doUpgrade() error {
 	if !s.didUpgrade {
 		s.Res.Header()[headerContentType] = headerContentTypeValue
-		if err := s.Res.Flush(); err != nil {
+		if err := s.flushResponse(); err != nil {
 			return err
 		}
 		s.didUpgrade = true
@@ -74,6 +314,47 @@ func (s *Session) doUpgrade() error {
 	return nil
 }
 
+func (s *Session) setWriteDeadline() error {
+	if s.WriteTimeout <= 0 || s.rc == nil {
+		return nil
+	}
+	if err := s.rc.SetWriteDeadline(time.Now().Add(s.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func (s *Session) clearWriteDeadline() {
+	if s.WriteTimeout > 0 && s.rc != nil {
+		_ = s.rc.SetWriteDeadline(time.Time{})
+	}
+}
+
+func (s *Session) wrapWriteErr(err error) error {
+	if s.WriteTimeout > 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+		return &WriteTimeoutError{Timeout: s.WriteTimeout, Err: err}
+	}
+	return err
+}
+
+// WriteTimeoutError is returned by a Session when writing to the client
+// takes longer than the configured WriteTimeout. This usually means that
+// the client stopped reading from the connection.
+type WriteTimeoutError struct {
+	// The error returned by the underlying http.ResponseWriter.
+	Err error
+	// The timeout which was exceeded.
+	Timeout time.Duration
+}
+
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("go-sse.server: write timed out after %s: %v", e.Timeout, e.Err)
+}
+
+func (e *WriteTimeoutError) Unwrap() error {
+	return e.Err
+}
+
 // Upgrade upgrades an HTTP request to support server-sent events.
 // It returns a Session that's used to send events to the client, or an
 // error if the upgrade failed.
@@ -96,12 +377,15 @@ func Upgrade(w http.ResponseWriter, r *http.Request) (*Session, error) {
 		id, _ = NewID(h[0])
 	}
 
-	return &Session{Req: r, Res: rw, LastEventID: id}, nil
+	return &Session{Req: r, Res: rw, LastEventID: id, rc: http.NewResponseController(w)}, nil
 }
 
 // ErrUpgradeUnsupported is returned when a request can't be upgraded to support server-sent events.
 var ErrUpgradeUnsupported = errors.New("go-sse.server: upgrade unsupported")
 
+// ErrSessionClosed is returned when messages are sent to a Session after it was closed.
+var ErrSessionClosed = errors.New("go-sse.server: session is closed")
+
 // Canonicalized header keys.
 const (
 	headerLastEventID = "Last-Event-Id"
@@ -0,0 +1,56 @@
+package sse
+
+import "net/http"
+
+// A MessageWriterMiddleware wraps the MessageWriter of a session, so that the messages
+// sent to it can be intercepted – rewritten, split, dropped or observed – before they
+// are written to the client. The returned MessageWriter must call the next one to
+// actually send the messages.
+//
+// The given request is the session's request, so middlewares can adapt their
+// behavior to each client – for example, to its version or its language.
+type MessageWriterMiddleware func(r *http.Request, next MessageWriter) MessageWriter
+
+// TransformMessages creates a middleware which replaces each message sent to a session
+// with the messages returned by the given function: no messages to drop it, a single one
+// to rewrite it, or multiple messages to split it. The transformation applies both to
+// live and replayed messages.
+//
+// The received message is shared between sessions, so it must not be modified – use its
+// Clone method to create a modified copy. The function is called synchronously by the
+// provider, so it should return as fast as possible.
+func TransformMessages(transform func(r *http.Request, m *Message) []*Message) MessageWriterMiddleware {
+	return func(r *http.Request, next MessageWriter) MessageWriter {
+		return &transformWriter{next: next, r: r, transform: transform}
+	}
+}
+
+type transformWriter struct {
+	next      MessageWriter
+	r         *http.Request
+	transform func(*http.Request, *Message) []*Message
+}
+
+func (t *transformWriter) Send(m *Message) error {
+	for _, tm := range t.transform(t.r, m) {
+		if err := t.next.Send(tm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *transformWriter) Flush() error {
+	return t.next.Flush()
+}
+
+// applyMiddleware wraps the writer with the given middlewares. The first middleware
+// is the outermost one, so it is the first to receive the messages.
+func applyMiddleware(r *http.Request, w MessageWriter, middlewares []MessageWriterMiddleware) MessageWriter {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		w = middlewares[i](r, w)
+	}
+
+	return w
+}
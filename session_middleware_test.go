@@ -0,0 +1,78 @@
+package sse_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tmaxmax/go-sse"
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func TestTransformMessages(t *testing.T) {
+	t.Parallel()
+
+	redact := sse.TransformMessages(func(r *http.Request, m *sse.Message) []*sse.Message {
+		if r.Header.Get("Role") == "admin" || !strings.Contains(m.String(), "secret") {
+			return []*sse.Message{m}
+		}
+		return nil
+	})
+	split := sse.TransformMessages(func(_ *http.Request, m *sse.Message) []*sse.Message {
+		c := m.Clone()
+		c.Type = sse.Type("copy")
+		return []*sse.Message{m, c}
+	})
+
+	var sent []string
+	flushed := false
+	client := mockClient(func(m *sse.Message) error {
+		if m == nil {
+			flushed = true
+		} else {
+			sent = append(sent, m.String())
+		}
+		return nil
+	})
+
+	req := httptest.NewRequest("", "/", http.NoBody)
+	req.Header.Set("Role", "user")
+
+	w := redact(req, split(req, client))
+	tests.Equal(t, w.Send(msg(t, "secret", "")), nil, "unexpected Send error")
+	tests.Equal(t, w.Send(msg(t, "public", "")), nil, "unexpected Send error")
+	tests.Equal(t, w.Flush(), nil, "unexpected Flush error")
+
+	tests.DeepEqual(t, sent, []string{"data: public\n\n", "event: copy\ndata: public\n\n"}, "invalid transformed messages")
+	tests.Expect(t, flushed, "flush should be forwarded")
+}
+
+func TestServer_SessionMiddleware(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	req, cancel := request(t, "", "/", http.NoBody)
+	go cancel()
+
+	var order []string
+	record := func(name string) sse.MessageWriterMiddleware {
+		return sse.TransformMessages(func(_ *http.Request, m *sse.Message) []*sse.Message {
+			order = append(order, name)
+			return []*sse.Message{m}
+		})
+	}
+	upper := sse.TransformMessages(func(_ *http.Request, m *sse.Message) []*sse.Message {
+		c := &sse.Message{}
+		c.AppendData(strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(m.String(), "data: "), "\n\n")))
+		return []*sse.Message{c}
+	})
+
+	(&sse.Server{
+		Provider:          newMockProvider(t, nil),
+		SessionMiddleware: []sse.MessageWriterMiddleware{record("first"), record("second"), upper},
+	}).ServeHTTP(rec, req)
+
+	tests.Equal(t, rec.Body.String(), "data: HELLO\n\n", "message should be transformed")
+	tests.DeepEqual(t, order, []string{"first", "second"}, "middlewares should be applied in order")
+}
@@ -2,10 +2,13 @@ package sse_test
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/tmaxmax/go-sse"
 	"github.com/tmaxmax/go-sse/internal/tests"
@@ -85,3 +88,136 @@ func TestUpgradedRequest_Send_error(t *testing.T) {
 	tests.ErrorIs(t, conn.Send(&sse.Message{ID: sse.ID("")}), errWriteFailed, "invalid Send error")
 	tests.Expect(t, rec.Flushed, "writer wasn't flushed")
 }
+
+func TestSession_SendEncoded(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+
+	sess, err := sse.Upgrade(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	tests.Equal(t, err, nil, "unexpected Upgrade error")
+
+	ev := &sse.Message{ID: sse.ID("1")}
+	ev.AppendData("sarmale")
+
+	tests.Equal(t, sess.SendEncoded(ev.Encode()), nil, "unexpected SendEncoded error")
+	tests.Equal(t, sess.Flush(), nil, "unexpected Flush error")
+	tests.Equal(t, rec.Header().Get("Content-Type"), "text/event-stream", "session wasn't upgraded")
+	tests.Equal(t, rec.Body.String(), ev.String(), "body not written correctly")
+}
+
+type notifyFlushWriter struct {
+	*httptest.ResponseRecorder
+	flushed chan string
+}
+
+func (n *notifyFlushWriter) Flush() {
+	n.flushed <- n.Body.String()
+	n.Body.Reset()
+}
+
+func TestSession_FlushPolicy(t *testing.T) {
+	t.Parallel()
+
+	ev := &sse.Message{}
+	ev.AppendData("hello")
+	expected := ev.String()
+
+	newSession := func(t *testing.T, p sse.FlushPolicy) (*sse.Session, *notifyFlushWriter) {
+		t.Helper()
+
+		w := &notifyFlushWriter{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan string, 8)}
+		sess, err := sse.Upgrade(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		tests.Equal(t, err, nil, "unexpected Upgrade error")
+		sess.FlushPolicy = p
+
+		return sess, w
+	}
+
+	t.Run("MaxLatency", func(t *testing.T) {
+		t.Parallel()
+
+		sess, w := newSession(t, sse.FlushPolicy{MaxLatency: time.Millisecond * 5})
+
+		tests.Equal(t, sess.Send(ev), nil, "unexpected Send error")
+		tests.Equal(t, <-w.flushed, "", "headers should be flushed on upgrade")
+		tests.Equal(t, sess.Flush(), nil, "unexpected Flush error")
+		tests.Equal(t, sess.SendEncoded(ev.Encode()), nil, "unexpected SendEncoded error")
+		tests.Equal(t, sess.Flush(), nil, "unexpected Flush error")
+		tests.Equal(t, <-w.flushed, expected+expected, "messages should be flushed together")
+		tests.Equal(t, sess.Close(), nil, "unexpected Close error")
+		tests.Equal(t, len(w.flushed), 0, "nothing should be flushed on Close")
+		tests.ErrorIs(t, sess.Send(ev), sse.ErrSessionClosed, "closed sessions should not send")
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		t.Parallel()
+
+		sess, w := newSession(t, sse.FlushPolicy{MaxLatency: time.Hour, MaxBytes: len(expected) * 2})
+
+		tests.Equal(t, sess.Send(ev), nil, "unexpected Send error")
+		tests.Equal(t, <-w.flushed, "", "headers should be flushed on upgrade")
+		tests.Equal(t, len(w.flushed), 0, "message should be buffered")
+		tests.Equal(t, sess.Send(ev), nil, "unexpected Send error")
+		tests.Equal(t, <-w.flushed, expected+expected, "messages should be flushed when buffer is full")
+		tests.Equal(t, sess.Send(ev), nil, "unexpected Send error")
+		tests.Equal(t, sess.Close(), nil, "unexpected Close error")
+		tests.Equal(t, <-w.flushed, expected, "buffered messages should be flushed on Close")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		t.Parallel()
+
+		rec := &errorWriter{}
+		sess, err := sse.Upgrade(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		tests.Equal(t, err, nil, "unexpected Upgrade error")
+		sess.FlushPolicy = sse.FlushPolicy{MaxLatency: time.Hour}
+
+		tests.Equal(t, sess.Send(ev), nil, "message should be buffered")
+		tests.ErrorIs(t, sess.Close(), errWriteFailed, "write error should be returned on Close")
+		tests.ErrorIs(t, sess.Send(ev), errWriteFailed, "write error should be returned after failure")
+	})
+}
+
+type deadlineWriter struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+	timedOut  bool
+}
+
+func (d *deadlineWriter) SetWriteDeadline(t time.Time) error {
+	d.deadlines = append(d.deadlines, t)
+	return nil
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if d.timedOut {
+		return 0, fmt.Errorf("write tcp: %w", os.ErrDeadlineExceeded)
+	}
+	return d.ResponseRecorder.Write(p)
+}
+
+func TestSession_WriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	w := &deadlineWriter{ResponseRecorder: httptest.NewRecorder()}
+	sess, err := sse.Upgrade(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	tests.Equal(t, err, nil, "unexpected Upgrade error")
+	sess.WriteTimeout = time.Minute
+
+	start := time.Now()
+	tests.Equal(t, sess.Send(&sse.Message{ID: sse.ID("1")}), nil, "unexpected Send error")
+	tests.Equal(t, len(w.deadlines), 2, "deadline should be set for the upgrade and the write")
+	tests.Expect(t, !w.deadlines[1].Before(start.Add(sess.WriteTimeout)), "invalid deadline")
+
+	w.timedOut = true
+
+	var timeoutErr *sse.WriteTimeoutError
+	err = sess.Send(&sse.Message{ID: sse.ID("2")})
+	tests.Expect(t, errors.As(err, &timeoutErr), "expected write timeout error, got %v", err)
+	tests.Equal(t, timeoutErr.Timeout, sess.WriteTimeout, "invalid timeout")
+	tests.ErrorIs(t, err, os.ErrDeadlineExceeded, "original error should be wrapped")
+
+	tests.Equal(t, sess.Close(), nil, "unexpected Close error")
+	tests.Expect(t, w.deadlines[len(w.deadlines)-1].IsZero(), "deadline should be cleared on Close")
+}
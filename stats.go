@@ -0,0 +1,64 @@
+package sse
+
+// ProviderStats is a snapshot of a provider's state.
+type ProviderStats struct {
+	// The number of subscribers of each topic. Subscribers which are subscribed
+	// to multiple topics are counted for each of them.
+	TopicSubscribers map[string]int
+	// The statistics of the provider's replayer. It is nil if the provider
+	// has no replayer or if the replayer doesn't report statistics.
+	Replayer *ReplayerStats
+	// The number of active subscribers.
+	Subscribers int
+	// The number of messages published since the provider was started.
+	Published uint64
+}
+
+// ReplayerStats is a snapshot of a replayer's state.
+type ReplayerStats struct {
+	// The number of messages stored in the replay buffer. Depending on
+	// the replayer, some of them may not be valid for replay anymore.
+	Buffered int
+	// The maximum number of messages the replay buffer can store,
+	// or 0 if the number of stored messages is not bounded.
+	Capacity int
+}
+
+// A StatsProvider is a Provider which can report statistics about its state.
+// Use it for health checks and dashboards.
+type StatsProvider interface {
+	// Stats returns a consistent snapshot of the provider's state.
+	// It returns ErrProviderClosed if the provider is closed.
+	Stats() (ProviderStats, error)
+}
+
+// A StatsReplayer is a Replayer which can report statistics about its state.
+// Just like the other Replayer methods, Stats is never called concurrently
+// with them by providers.
+type StatsReplayer interface {
+	// Stats returns a snapshot of the replayer's state.
+	Stats() ReplayerStats
+}
+
+// A HistoryProvider is a Provider whose replay history can be inspected and purged.
+type HistoryProvider interface {
+	// History returns the stored messages of the given topic which can still be replayed,
+	// oldest first. It returns errors.ErrUnsupported if the provider's replayer doesn't
+	// implement HistoryReplayer and ErrProviderClosed if the provider is closed.
+	History(topic string) ([]*Message, error)
+	// Purge removes the given topic's history, so its messages aren't replayed anymore.
+	// It returns the number of purged messages. It returns the same errors as History.
+	Purge(topic string) (int, error)
+}
+
+// A HistoryReplayer is a Replayer whose stored messages can be inspected and purged.
+// Just like the other Replayer methods, its methods are never called concurrently
+// with them by providers.
+type HistoryReplayer interface {
+	// History returns the stored messages of the given topic which can still be replayed,
+	// oldest first. The returned messages must not be modified.
+	History(topic string) []*Message
+	// Purge removes the given topic from all the stored messages, so they aren't replayed
+	// to its subscribers anymore. It returns the number of affected messages.
+	Purge(topic string) int
+}
@@ -0,0 +1,195 @@
+package sse
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	wheelSlots = 512
+	wheelTick  = 10 * time.Millisecond
+)
+
+// timerWheel is a hashed timing wheel: scheduling and canceling a message are O(1),
+// regardless of how many messages are pending. Pending messages are kept in slots
+// which are visited once per tick; messages scheduled further than a full rotation
+// of the wheel wait for the required number of rounds in their slot.
+//
+// The wheel runs a goroutine only while it has pending messages.
+type timerWheel struct {
+	base time.Time
+	// fire is called, outside the lock and in order, with the messages which are due.
+	fire func(due []*ScheduledMessage)
+	// fireMu is held from advancing the wheel until the due messages are fired, so that
+	// a goroutine started by add doesn't fire its messages before the previous one is done.
+	fireMu  sync.Mutex
+	stopc   chan struct{}
+	slots   [wheelSlots]map[*ScheduledMessage]struct{}
+	current int64
+	seq     uint64
+	pending int
+	mu      sync.Mutex
+	running bool
+	stopped bool
+}
+
+func newTimerWheel(fire func(due []*ScheduledMessage)) *timerWheel {
+	w := &timerWheel{base: time.Now(), fire: fire, stopc: make(chan struct{})}
+	for i := range w.slots {
+		w.slots[i] = map[*ScheduledMessage]struct{}{}
+	}
+
+	return w
+}
+
+// ticksAt returns the number of ticks elapsed from the wheel's start until the given time.
+func (w *timerWheel) ticksAt(t time.Time) int64 {
+	return int64(t.Sub(w.base) / wheelTick)
+}
+
+// add schedules the message. It returns false if the wheel is stopped.
+func (w *timerWheel) add(m *ScheduledMessage) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return false
+	}
+
+	if !w.running {
+		// There's nothing pending, so the elapsed ticks can be skipped.
+		w.current = w.ticksAt(time.Now())
+	}
+
+	// Messages fire on the first tick at or after their time.
+	target := int64((m.at.Sub(w.base) + wheelTick - 1) / wheelTick)
+	if target <= w.current {
+		target = w.current + 1
+	}
+
+	w.seq++
+	m.seq = w.seq
+	m.slot = int(target % wheelSlots)
+	m.rounds = (target - w.current - 1) / wheelSlots
+	w.slots[m.slot][m] = struct{}{}
+	w.pending++
+
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+
+	return true
+}
+
+// remove cancels the message. It returns false if the message isn't pending anymore.
+func (w *timerWheel) remove(m *ScheduledMessage) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.slots[m.slot][m]; !ok {
+		return false
+	}
+
+	delete(w.slots[m.slot], m)
+	w.pending--
+
+	return true
+}
+
+// stop stops the wheel and returns the messages which are still pending.
+func (w *timerWheel) stop() []*ScheduledMessage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return nil
+	}
+
+	w.stopped = true
+	close(w.stopc)
+
+	var pending []*ScheduledMessage
+	for i := range w.slots {
+		for m := range w.slots[i] {
+			pending = append(pending, m)
+		}
+		clear(w.slots[i])
+	}
+	w.pending = 0
+
+	return pending
+}
+
+func (w *timerWheel) run() {
+	t := time.NewTicker(wheelTick)
+	defer t.Stop()
+
+	for {
+		select {
+		case now := <-t.C:
+			if !w.tick(now) {
+				return
+			}
+		case <-w.stopc:
+			return
+		}
+	}
+}
+
+// tick fires the messages which are due at the given time.
+// It reports whether there are still pending messages.
+func (w *timerWheel) tick(now time.Time) bool {
+	w.fireMu.Lock()
+	defer w.fireMu.Unlock()
+
+	due, more := w.advance(now)
+	if len(due) > 0 {
+		w.fire(due)
+	}
+
+	return more
+}
+
+// advance visits all the slots up to the given time and removes the due messages.
+// It reports whether there are still pending messages.
+func (w *timerWheel) advance(now time.Time) (due []*ScheduledMessage, more bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stopped {
+		return nil, false
+	}
+
+	for target := w.ticksAt(now); w.current < target && w.pending > 0; {
+		w.current++
+
+		slot := w.slots[w.current%wheelSlots]
+		for m := range slot {
+			if m.rounds > 0 {
+				m.rounds--
+				continue
+			}
+
+			delete(slot, m)
+			w.pending--
+			due = append(due, m)
+		}
+	}
+
+	slices.SortFunc(due, func(a, b *ScheduledMessage) int {
+		if c := a.at.Compare(b.at); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	if w.pending == 0 {
+		w.running = false
+		return due, false
+	}
+
+	return due, true
+}
@@ -0,0 +1,69 @@
+package sse
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse/internal/tests"
+)
+
+func TestTimerWheel_rounds(t *testing.T) {
+	t.Parallel()
+
+	w := newTimerWheel(func([]*ScheduledMessage) {})
+	t.Cleanup(func() { w.stop() })
+
+	// The messages are far enough in the future so that the wheel's own goroutine doesn't get to them.
+	rotation := wheelTick * wheelSlots
+	near := &ScheduledMessage{at: w.base.Add(rotation / 2)}
+	far := &ScheduledMessage{at: w.base.Add(rotation*2 + rotation/2)}
+	tests.Expect(t, w.add(far), "message should be scheduled")
+	tests.Expect(t, w.add(near), "message should be scheduled")
+	tests.Equal(t, far.slot, near.slot, "messages should share the slot")
+
+	due, more := w.advance(near.at)
+	tests.DeepEqual(t, due, []*ScheduledMessage{near}, "only the near message should be due")
+	tests.Expect(t, more, "the far message should still be pending")
+
+	due, _ = w.advance(far.at.Add(-wheelTick))
+	tests.Equal(t, len(due), 0, "the far message should not be due before its rounds pass")
+
+	due, more = w.advance(far.at)
+	tests.DeepEqual(t, due, []*ScheduledMessage{far}, "the far message should be due")
+	tests.Expect(t, !more, "no messages should be pending")
+}
+
+func TestTimerWheel_firesInOrder(t *testing.T) {
+	t.Parallel()
+
+	firing := make(chan struct{})
+	release := make(chan struct{})
+	fired := make(chan *ScheduledMessage, 2)
+
+	var calls atomic.Int32
+	w := newTimerWheel(func(due []*ScheduledMessage) {
+		// The first call blocks, so the wheel has nothing pending while it fires.
+		if calls.Add(1) == 1 {
+			close(firing)
+			<-release
+		}
+		for _, m := range due {
+			fired <- m
+		}
+	})
+	t.Cleanup(func() { w.stop() })
+
+	first := &ScheduledMessage{at: time.Now()}
+	tests.Expect(t, w.add(first), "message should be scheduled")
+	<-firing
+
+	// This starts another goroutine, which must wait for the first one to fire.
+	second := &ScheduledMessage{at: time.Now()}
+	tests.Expect(t, w.add(second), "message should be scheduled")
+	time.Sleep(5 * wheelTick)
+	close(release)
+
+	tests.Equal(t, <-fired, first, "the first message should be fired first")
+	tests.Equal(t, <-fired, second, "the second message should be fired second")
+}